@@ -4,6 +4,8 @@ import "context"
 
 type tokenAuth struct {
 	token string
+	// Set only when dialing a loopback address in plaintext.
+	insecure bool
 }
 
 func (t tokenAuth) GetRequestMetadata(ctx context.Context, in ...string) (map[string]string, error) {
@@ -12,6 +14,6 @@ func (t tokenAuth) GetRequestMetadata(ctx context.Context, in ...string) (map[st
 	}, nil
 }
 
-func (tokenAuth) RequireTransportSecurity() bool {
-	return true
+func (t tokenAuth) RequireTransportSecurity() bool {
+	return !t.insecure
 }
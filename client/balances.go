@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	"github.com/cosmos/cosmos-sdk/types/query"
+	bankTypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/figment-networks/indexing-engine/structs"
+	"google.golang.org/grpc/metadata"
+)
+
+// GetAccountBalances returns the account's liquid balances by denom at the height.
+func (c *client) GetAccountBalances(
+	ctx context.Context,
+	params structs.HeightAccount,
+) (balances Coins, err error) {
+
+	heightCtx := metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatUint(params.Height, 10))
+
+	balances = Coins{}
+	var nextKey []byte
+	for {
+		resp, err := c.bankClient.AllBalances(
+			heightCtx,
+			&bankTypes.QueryAllBalancesRequest{
+				Address:    params.Account,
+				Pagination: &query.PageRequest{Key: nextKey, Limit: c.pageSize},
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("[COSMOS-API] Error fetching balances: %w", err)
+		}
+
+		for _, coin := range resp.Balances {
+			balances[coin.Denom] = coin.Amount.BigInt()
+		}
+
+		if resp.Pagination == nil || len(resp.Pagination.NextKey) == 0 {
+			break
+		}
+		nextKey = resp.Pagination.NextKey
+	}
+
+	return balances, nil
+}
+
+// GetDelegationTotalRewards returns the account's unclaimed rewards by denom at the height.
+func (c *client) GetDelegationTotalRewards(
+	ctx context.Context,
+	params structs.HeightAccount,
+) (total Coins, err error) {
+
+	pending, err := c.getPendingRewards(ctx, params.Account, params.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	total = Coins{}
+	for _, coins := range pending {
+		total.AddCoins(coins)
+	}
+
+	return total, nil
+}
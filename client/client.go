@@ -4,27 +4,56 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
-	"math/big"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/figment-networks/cosmos-worker/api"
 	"github.com/figment-networks/indexing-engine/structs"
 
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	bankTypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	distrTypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
 	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
 
+// Supported values for Config.TLSMode. An empty mode behaves like TLSModeSystem.
+const (
+	TLSModeInsecure = "insecure"
+	TLSModeSystem   = "system"
+	TLSModeCAFile   = "ca-file"
+	TLSModeMTLS     = "mtls"
+)
+
+const defaultPageSize = 100
+
 type Config struct {
-	GRPCAddr               string
-	SearchAddr             string
-	AuthToken              string
-	GRPCMaxRecvSize        int
-	GRPCMaxSendSize        int
-	RequestsPerSecond      int
+	GRPCAddr          string
+	SearchAddr        string
+	AuthToken         string
+	TLSMode           string
+	TLSCAFile         string
+	TLSCertFile       string
+	TLSKeyFile        string
+	HeightSource      string
+	RewardsSource     string
+	GRPCMaxRecvSize   int
+	GRPCMaxSendSize   int
+	RequestsPerSecond int
+	// Limiter is shared by all gRPC and search calls. When nil one is created from RequestsPerSecond;
+	// pass the same limiter to several clients to limit them together.
+	Limiter *rate.Limiter
+	Retry   RetryConfig
+	// PageSize is the number of items requested per page from paginated endpoints.
+	PageSize               uint64
 	TimeoutBlockCall       time.Duration
 	TimeoutTransactionCall time.Duration
 }
@@ -35,35 +64,77 @@ type Client interface {
 		ctx context.Context,
 		params structs.HeightAccount,
 	) (resp structs.GetAccountDelegationsResponse, err error)
+	GetUnbondingDelegations(ctx context.Context, params structs.HeightAccount) (unbondings []UnbondingDelegation, err error)
+	GetRedelegations(ctx context.Context, params structs.HeightAccount) (redelegations []Redelegation, err error)
+	GetAccountBalances(ctx context.Context, params structs.HeightAccount) (balances Coins, err error)
+	GetDelegationTotalRewards(ctx context.Context, params structs.HeightAccount) (total Coins, err error)
+	GetDelegationRewards(ctx context.Context, params structs.HeightAccount) (pending map[string]Coins, err error)
+	GetDenomsMetadata(ctx context.Context) (units map[string]DenomUnit, err error)
+	GetValidators(ctx context.Context, height uint64) (validators []Validator, err error)
+	GetBlockTime(ctx context.Context, height uint64) (time.Time, error)
+	GetLatestHeight(ctx context.Context) (uint64, error)
 	GetLastHeightBefore(ctx context.Context, req LastHeightBeforeReq) (height uint64, err error)
-	GetRewardsAndFeesSum(ctx context.Context, req RewardsReq) (rewards map[string]*big.Int, fees map[string]*big.Int, err error)
+	GetRewards(ctx context.Context, req RewardsReq) (entries []RewardEntry, err error)
+	GetRewardsAndFeesSum(ctx context.Context, req RewardsReq) (rewards map[string]Coins, fees map[string]Coins, err error)
 }
 
 type client struct {
-	apiClient     *api.Client
-	grpcConn      *grpc.ClientConn
-	stakingClient stakingTypes.QueryClient
-	authToken     string
-	searchAddr    string
-	searchClient  http.Client
+	apiClient          *api.Client
+	grpcConn           *grpc.ClientConn
+	stakingClient      stakingTypes.QueryClient
+	tmClient           tmservice.ServiceClient
+	distributionClient distrTypes.QueryClient
+	bankClient         bankTypes.QueryClient
+	txClient           tx.ServiceClient
+	authToken          string
+	searchAddr         string
+	searchClient       http.Client
+	heightSource       string
+	heightCache        *heightCache
+	commissionCache    *commissionCache
+	bondDenomCache     *bondDenomCache
+	rewardsSource      string
+	limiter            *rate.Limiter
+	retrier            retrier
+	pageSize           uint64
 }
 
 func New(ctx context.Context, logger *zap.Logger, cfg Config) (c Client, err error) {
 
-	tlsc, err := loadTLSCredentials()
-	if err != nil {
-		return
+	limiter := cfg.Limiter
+	if limiter == nil {
+		limiter = NewLimiter(cfg.RequestsPerSecond)
+	}
+
+	retry := retrier{cfg: cfg.Retry, logger: logger}
+
+	pageSize := cfg.PageSize
+	if pageSize == 0 {
+		pageSize = defaultPageSize
 	}
 
 	dialOptions := []grpc.DialOption{
 		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(cfg.GRPCMaxRecvSize)),
 		grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(cfg.GRPCMaxSendSize)),
-		grpc.WithTransportCredentials(tlsc),
-		grpc.WithPerRPCCredentials(tokenAuth{
-			token: cfg.AuthToken,
-		}),
+		// Retries are outermost so every attempt waits for the limiter.
+		grpc.WithChainUnaryInterceptor(retryInterceptor(retry), rateLimitInterceptor(limiter)),
+	}
+
+	// The search endpoint is reached with the same TLS settings as gRPC, so a private CA or client
+	// certificate applies to both.
+	var tlsConfig *tls.Config
+	if cfg.TLSMode != TLSModeInsecure {
+		if tlsConfig, err = loadTLSConfig(cfg); err != nil {
+			return
+		}
 	}
 
+	securityOptions, err := transportDialOptions(cfg, tlsConfig)
+	if err != nil {
+		return
+	}
+	dialOptions = append(dialOptions, securityOptions...)
+
 	grpcConn, err := grpc.DialContext(ctx, cfg.GRPCAddr, dialOptions...)
 	if err != nil {
 		err = fmt.Errorf("error dialing grpc: %w", err)
@@ -79,12 +150,24 @@ func New(ctx context.Context, logger *zap.Logger, cfg Config) (c Client, err err
 	}
 
 	return &client{
-		apiClient:     api.NewClient(logger, grpcConn, &clientConfig),
-		stakingClient: stakingTypes.NewQueryClient(grpcConn),
-		grpcConn:      grpcConn,
-		authToken:     cfg.AuthToken,
-		searchAddr:    cfg.SearchAddr,
-		searchClient:  http.Client{},
+		apiClient:          api.NewClient(logger, grpcConn, &clientConfig),
+		stakingClient:      stakingTypes.NewQueryClient(grpcConn),
+		tmClient:           tmservice.NewServiceClient(grpcConn),
+		distributionClient: distrTypes.NewQueryClient(grpcConn),
+		bankClient:         bankTypes.NewQueryClient(grpcConn),
+		txClient:           tx.NewServiceClient(grpcConn),
+		grpcConn:           grpcConn,
+		authToken:          cfg.AuthToken,
+		searchAddr:         cfg.SearchAddr,
+		searchClient:       newSearchClient(tlsConfig),
+		heightSource:       cfg.HeightSource,
+		heightCache:        newHeightCache(),
+		commissionCache:    newCommissionCache(),
+		bondDenomCache:     newBondDenomCache(),
+		rewardsSource:      cfg.RewardsSource,
+		limiter:            limiter,
+		retrier:            retry,
+		pageSize:           pageSize,
 	}, nil
 }
 
@@ -92,13 +175,123 @@ func (c *client) Close() {
 	c.grpcConn.Close()
 }
 
-func loadTLSCredentials() (credentials.TransportCredentials, error) {
-	ootCAs, err := x509.SystemCertPool()
+// transportDialOptions returns the transport and per-RPC credential options for the configured TLS mode.
+// tlsConfig is nil in insecure mode.
+func transportDialOptions(cfg Config, tlsConfig *tls.Config) ([]grpc.DialOption, error) {
+
+	if cfg.TLSMode == TLSModeInsecure {
+		opts := []grpc.DialOption{grpc.WithInsecure()}
+		if cfg.AuthToken == "" {
+			return opts, nil
+		}
+
+		// Never send the token in plaintext anywhere other than this host.
+		if !IsLoopbackAddr(cfg.GRPCAddr) {
+			return nil, fmt.Errorf("auth token can only be sent over plaintext to a loopback address, got %s", cfg.GRPCAddr)
+		}
+
+		return append(opts, grpc.WithPerRPCCredentials(tokenAuth{token: cfg.AuthToken, insecure: true})), nil
+	}
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig.Clone()))}
+	if cfg.AuthToken != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenAuth{token: cfg.AuthToken}))
+	}
+
+	return opts, nil
+}
+
+// newSearchClient returns the HTTP client for search calls, verifying the server and presenting client
+// certificates as tlsConfig says. tlsConfig is nil in insecure mode.
+func newSearchClient(tlsConfig *tls.Config) http.Client {
+
+	if tlsConfig == nil {
+		return http.Client{}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig.Clone()
+
+	return http.Client{Transport: transport}
+}
+
+func loadTLSConfig(cfg Config) (*tls.Config, error) {
+
+	tlsConfig := &tls.Config{}
+
+	switch cfg.TLSMode {
+	case "", TLSModeSystem:
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = rootCAs
+	case TLSModeCAFile:
+		rootCAs, err := loadCertPool(cfg.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = rootCAs
+	case TLSModeMTLS:
+		// The CA file is optional for mutual TLS; fall back to the system pool when it is absent.
+		var rootCAs *x509.CertPool
+		var err error
+		if cfg.TLSCAFile != "" {
+			rootCAs, err = loadCertPool(cfg.TLSCAFile)
+		} else {
+			rootCAs, err = x509.SystemCertPool()
+		}
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = rootCAs
+
+		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+			return nil, errors.New("tls mode mtls requires a client certificate and key")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	default:
+		return nil, fmt.Errorf("unknown tls mode %q", cfg.TLSMode)
+	}
+
+	return tlsConfig, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+
+	if path == "" {
+		return nil, errors.New("tls ca file is not set")
+	}
+
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading tls ca file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in tls ca file %s", path)
+	}
+
+	return pool, nil
+}
+
+// IsLoopbackAddr reports whether the address is on this host, the only one a token is sent to in plaintext.
+func IsLoopbackAddr(addr string) bool {
+
+	host, _, err := net.SplitHostPort(addr)
 	if err != nil {
-		return nil, err
+		host = addr
+	}
+
+	if host == "localhost" {
+		return true
 	}
 
-	return credentials.NewTLS(&tls.Config{
-		RootCAs: ootCAs,
-	}), nil
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
 }
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"math/big"
+	"sort"
+)
+
+// Coins are amounts by denom.
+type Coins map[string]*big.Int
+
+// Add adds the amount of the denom without modifying amount.
+func (c Coins) Add(denom string, amount *big.Int) {
+	if sum, ok := c[denom]; ok {
+		c[denom] = sum.Add(sum, amount)
+	} else {
+		c[denom] = new(big.Int).Set(amount)
+	}
+}
+
+// AddCoins adds all amounts of other.
+func (c Coins) AddCoins(other Coins) {
+	for denom, amount := range other {
+		c.Add(denom, amount)
+	}
+}
+
+// Denoms returns the sorted denoms.
+func (c Coins) Denoms() []string {
+	denoms := make([]string, 0, len(c))
+	for d := range c {
+		denoms = append(denoms, d)
+	}
+	sort.Strings(denoms)
+	return denoms
+}
@@ -9,6 +9,7 @@ import (
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	"github.com/cosmos/cosmos-sdk/types/query"
 	"github.com/cosmos/cosmos-sdk/x/staking/types"
 	"google.golang.org/grpc/metadata"
 )
@@ -20,15 +21,32 @@ func (c *client) GetAccountDelegations(
 
 	resp.Height = params.Height
 
-	delResp, err := c.stakingClient.DelegatorDelegations(
-		metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatUint(params.Height, 10)),
-		&types.QueryDelegatorDelegationsRequest{DelegatorAddr: params.Account},
-	)
-	if err != nil {
-		return resp, fmt.Errorf("[COSMOS-API] Error fetching delegations: %w", err)
+	heightCtx := metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatUint(params.Height, 10))
+
+	// Nodes cap the page size, so walk every page until there is no next key.
+	var delegations []types.DelegationResponse
+	var nextKey []byte
+	for {
+		delResp, err := c.stakingClient.DelegatorDelegations(
+			heightCtx,
+			&types.QueryDelegatorDelegationsRequest{
+				DelegatorAddr: params.Account,
+				Pagination:    &query.PageRequest{Key: nextKey, Limit: c.pageSize},
+			},
+		)
+		if err != nil {
+			return resp, fmt.Errorf("[COSMOS-API] Error fetching delegations: %w", err)
+		}
+
+		delegations = append(delegations, delResp.DelegationResponses...)
+
+		if delResp.Pagination == nil || len(delResp.Pagination.NextKey) == 0 {
+			break
+		}
+		nextKey = delResp.Pagination.NextKey
 	}
 
-	for _, dr := range delResp.DelegationResponses {
+	for _, dr := range delegations {
 		resp.Delegations = append(resp.Delegations,
 			structs.Delegation{
 				Delegator: dr.Delegation.DelegatorAddress,
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/types/query"
+	bankTypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"google.golang.org/grpc/codes"
+)
+
+// DenomUnit is the display unit of a base denom and its power of ten.
+type DenomUnit struct {
+	Display  string `json:"display"`
+	Exponent uint32 `json:"exponent"`
+}
+
+// GetDenomsMetadata returns the display unit of every base denom with bank metadata.
+func (c *client) GetDenomsMetadata(ctx context.Context) (units map[string]DenomUnit, err error) {
+
+	units = map[string]DenomUnit{}
+	var nextKey []byte
+	for {
+		resp, err := c.bankClient.DenomsMetadata(ctx, &bankTypes.QueryDenomsMetadataRequest{
+			Pagination: &query.PageRequest{Key: nextKey, Limit: c.pageSize},
+		})
+		if err != nil {
+			if grpcCode(err) == codes.Unimplemented {
+				return units, nil
+			}
+			return nil, fmt.Errorf("[COSMOS-API] Error fetching denoms metadata: %w", err)
+		}
+
+		for _, md := range resp.Metadatas {
+			for _, u := range md.DenomUnits {
+				if u.Denom == md.Display {
+					units[md.Base] = DenomUnit{Display: md.Display, Exponent: u.Exponent}
+					break
+				}
+			}
+		}
+
+		if resp.Pagination == nil || len(resp.Pagination.NextKey) == 0 {
+			break
+		}
+		nextKey = resp.Pagination.NextKey
+	}
+
+	return units, nil
+}
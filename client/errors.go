@@ -0,0 +1,72 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrNoHeights is returned when there is no block before the requested time.
+	ErrNoHeights = errors.New("no heights found")
+	// ErrUnauthorized is wrapped by search errors for responses rejecting the auth token.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrRateLimited is wrapped by search errors for responses asking us to slow down.
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// maxBodyExcerpt is how much of an error response body is kept in a SearchError.
+const maxBodyExcerpt = 512
+
+// SearchError is a non-200 response from the search service.
+type SearchError struct {
+	StatusCode int
+	Endpoint   string
+	// RequestID is the ID the service assigned to the request, if it returned one.
+	RequestID  string
+	Body       string
+	RetryAfter time.Duration
+}
+
+func newSearchError(resp *http.Response, endpoint string, body []byte) *SearchError {
+
+	if len(body) > maxBodyExcerpt {
+		body = body[:maxBodyExcerpt]
+	}
+
+	return &SearchError{
+		StatusCode: resp.StatusCode,
+		Endpoint:   endpoint,
+		RequestID:  resp.Header.Get("X-Request-Id"),
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func (e *SearchError) Error() string {
+	msg := fmt.Sprintf("search %s responded with status %d", e.Endpoint, e.StatusCode)
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// Unwrap lets callers check for ErrUnauthorized and ErrRateLimited with errors.Is.
+func (e *SearchError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// Retriable reports whether the request may succeed if sent again.
+func (e *SearchError) Retriable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,265 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	"google.golang.org/grpc/codes"
+)
+
+// Supported values for Config.HeightSource. An empty source behaves like HeightSourceSearch.
+const (
+	HeightSourceSearch = "search"
+	HeightSourceNode   = "node"
+)
+
+func (c client) GetBlockTime(ctx context.Context, height uint64) (time.Time, error) {
+	return c.getBlockTime(ctx, height)
+}
+
+// GetLatestHeight returns the height of the node's latest block.
+func (c client) GetLatestHeight(ctx context.Context) (uint64, error) {
+
+	s, err := c.latestBlockSample(ctx)
+	return s.height, err
+}
+
+func (c client) GetLastHeightBefore(ctx context.Context, req LastHeightBeforeReq) (height uint64, err error) {
+
+	if c.heightSource == HeightSourceNode {
+		return c.nodeLastHeightBefore(ctx, req.BeforeTime)
+	}
+
+	return c.searchLastHeightBefore(ctx, req)
+}
+
+// nodeLastHeightBefore binary searches block headers on the node for the last height with a block time
+// before the provided time. Every header fetched along the way is cached so later lookups, which are
+// usually for nearby times, start from a much narrower range.
+func (c client) nodeLastHeightBefore(ctx context.Context, before time.Time) (height uint64, err error) {
+
+	latest, err := c.latestBlockSample(ctx)
+	if err != nil {
+		return
+	}
+
+	if latest.time.Before(before) {
+		return latest.height, nil
+	}
+
+	earliest, err := c.earliestBlockSample(ctx, latest)
+	if err != nil {
+		return
+	}
+
+	if !earliest.time.Before(before) {
+		err = fmt.Errorf("%w before time %s", ErrNoHeights, before)
+		return
+	}
+
+	// The invariant is that lo is always before the time and hi never is.
+	lo, hi := c.heightCache.bounds(before, earliest, latest)
+	for hi.height-lo.height > 1 {
+		mid := lo.height + (hi.height-lo.height)/2
+
+		var blockTime time.Time
+		blockTime, err = c.getBlockTime(ctx, mid)
+		if err != nil {
+			return
+		}
+
+		if blockTime.Before(before) {
+			lo = heightSample{height: mid, time: blockTime}
+		} else {
+			hi = heightSample{height: mid, time: blockTime}
+		}
+	}
+
+	return lo.height, nil
+}
+
+// earliestHeight returns the lowest height the node still has a block for.
+func (c client) earliestHeight(ctx context.Context) (uint64, error) {
+
+	latest, err := c.latestBlockSample(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	earliest, err := c.earliestBlockSample(ctx, latest)
+	return earliest.height, err
+}
+
+func (c client) latestBlockSample(ctx context.Context) (s heightSample, err error) {
+
+	resp, err := c.tmClient.GetLatestBlock(ctx, &tmservice.GetLatestBlockRequest{})
+	if err != nil {
+		err = fmt.Errorf("[COSMOS-API] Error fetching latest block: %w", err)
+		return
+	}
+
+	s = heightSample{height: uint64(resp.Block.Header.Height), time: resp.Block.Header.Time}
+	c.heightCache.add(s)
+
+	return
+}
+
+// earliestBlockSample finds the lowest height the node still has a block for. Archive nodes of chains that
+// were started from an exported genesis, as well as pruned nodes, don't serve blocks from height 1.
+func (c client) earliestBlockSample(ctx context.Context, latest heightSample) (s heightSample, err error) {
+
+	if earliest, ok := c.heightCache.earliestSample(); ok {
+		return earliest, nil
+	}
+
+	blockTime, available, err := c.probeBlockTime(ctx, 1)
+	if err != nil {
+		return
+	}
+
+	if available {
+		s = heightSample{height: 1, time: blockTime}
+		c.heightCache.setEarliest(s)
+		return
+	}
+
+	// Height lo is unavailable and hi is available.
+	lo := uint64(1)
+	hi := latest
+	for hi.height-lo > 1 {
+		mid := lo + (hi.height-lo)/2
+		blockTime, available, err = c.probeBlockTime(ctx, mid)
+		if err != nil {
+			return
+		}
+
+		if available {
+			hi = heightSample{height: mid, time: blockTime}
+		} else {
+			lo = mid
+		}
+	}
+
+	c.heightCache.setEarliest(hi)
+
+	return hi, nil
+}
+
+func (c client) getBlockTime(ctx context.Context, height uint64) (t time.Time, err error) {
+
+	if t, ok := c.heightCache.get(height); ok {
+		return t, nil
+	}
+
+	resp, err := c.tmClient.GetBlockByHeight(ctx, &tmservice.GetBlockByHeightRequest{Height: int64(height)})
+	if err != nil {
+		err = fmt.Errorf("[COSMOS-API] Error fetching block %d: %w", height, err)
+		return
+	}
+
+	t = resp.Block.Header.Time
+	c.heightCache.add(heightSample{height: height, time: t})
+
+	return
+}
+
+// probeBlockTime is like getBlockTime but reports heights the node doesn't have a block for as unavailable
+// instead of failing. Connection problems are still returned as errors.
+func (c client) probeBlockTime(ctx context.Context, height uint64) (t time.Time, available bool, err error) {
+
+	t, err = c.getBlockTime(ctx, height)
+	if err == nil {
+		return t, true, nil
+	}
+
+	switch grpcCode(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled, codes.Unauthenticated, codes.ResourceExhausted:
+		return
+	}
+
+	return t, false, nil
+}
+
+type heightSample struct {
+	height uint64
+	time   time.Time
+}
+
+// heightCache holds block times already fetched from the node, ordered by height.
+type heightCache struct {
+	lock     sync.RWMutex
+	samples  []heightSample
+	earliest *heightSample
+}
+
+func newHeightCache() *heightCache {
+	return &heightCache{}
+}
+
+func (hc *heightCache) get(height uint64) (time.Time, bool) {
+	hc.lock.RLock()
+	defer hc.lock.RUnlock()
+
+	i := sort.Search(len(hc.samples), func(i int) bool { return hc.samples[i].height >= height })
+	if i < len(hc.samples) && hc.samples[i].height == height {
+		return hc.samples[i].time, true
+	}
+
+	return time.Time{}, false
+}
+
+func (hc *heightCache) add(s heightSample) {
+	hc.lock.Lock()
+	defer hc.lock.Unlock()
+
+	i := sort.Search(len(hc.samples), func(i int) bool { return hc.samples[i].height >= s.height })
+	if i < len(hc.samples) && hc.samples[i].height == s.height {
+		return
+	}
+
+	hc.samples = append(hc.samples, heightSample{})
+	copy(hc.samples[i+1:], hc.samples[i:])
+	hc.samples[i] = s
+}
+
+// bounds narrows the range [lo, hi] to the closest cached samples on either side of the provided time.
+func (hc *heightCache) bounds(before time.Time, lo, hi heightSample) (heightSample, heightSample) {
+	hc.lock.RLock()
+	defer hc.lock.RUnlock()
+
+	for _, s := range hc.samples {
+		if s.height < lo.height || s.height > hi.height {
+			continue
+		}
+		if s.time.Before(before) {
+			lo = s
+		} else {
+			hi = s
+			break
+		}
+	}
+
+	return lo, hi
+}
+
+func (hc *heightCache) earliestSample() (heightSample, bool) {
+	hc.lock.RLock()
+	defer hc.lock.RUnlock()
+
+	if hc.earliest == nil {
+		return heightSample{}, false
+	}
+
+	return *hc.earliest, true
+}
+
+func (hc *heightCache) setEarliest(s heightSample) {
+	hc.lock.Lock()
+	defer hc.lock.Unlock()
+
+	hc.earliest = &s
+}
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+)
+
+// NewLimiter returns a limiter allowing the given number of requests per second. A non-positive rate
+// disables limiting.
+func NewLimiter(requestsPerSecond int) *rate.Limiter {
+	if requestsPerSecond <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	return rate.NewLimiter(rate.Limit(requestsPerSecond), 1)
+}
+
+// rateLimitInterceptor makes every unary gRPC call wait for the limiter.
+func rateLimitInterceptor(limiter *rate.Limiter) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
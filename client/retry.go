@@ -0,0 +1,185 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryConfig controls how transient gRPC and search failures are retried. Zero values disable retries.
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts per call, including the first.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Budget caps the total time spent on a call, including waiting between attempts.
+	Budget time.Duration
+}
+
+type retrier struct {
+	cfg    RetryConfig
+	logger *zap.Logger
+}
+
+// do calls fn until it succeeds, fails with a permanent error, or the attempts or budget run out.
+func (r retrier) do(ctx context.Context, operation string, fn func() error) error {
+
+	start := time.Now()
+	backoff := r.cfg.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		retriable, retryAfter := classifyError(err)
+		if !retriable || attempt >= r.cfg.MaxAttempts {
+			return err
+		}
+
+		wait := jitter(backoff)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		if r.cfg.Budget > 0 && time.Since(start)+wait > r.cfg.Budget {
+			return err
+		}
+
+		r.logger.Warn("Retrying failed call",
+			zap.String("operation", operation),
+			zap.Int("attempt", attempt),
+			zap.Duration("wait", wait),
+			zap.Error(err),
+		)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if r.cfg.MaxBackoff > 0 && backoff > r.cfg.MaxBackoff {
+			backoff = r.cfg.MaxBackoff
+		}
+	}
+}
+
+// jitter returns a random duration between half and all of the backoff, so concurrent workers that
+// failed together don't retry together.
+func jitter(backoff time.Duration) time.Duration {
+	if backoff <= 0 {
+		return 0
+	}
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// classifyError reports whether the error is worth retrying and how long the server asked us to wait.
+func classifyError(err error) (retriable bool, retryAfter time.Duration) {
+
+	var se *SearchError
+	if errors.As(err, &se) {
+		return se.Retriable(), se.RetryAfter
+	}
+
+	switch grpcCode(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded:
+		return true, 0
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true, 0
+	}
+
+	return false, 0
+}
+
+// grpcCode returns the gRPC status code of the error, looking through any wrapping.
+func grpcCode(err error) codes.Code {
+	var se interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &se) {
+		return se.GRPCStatus().Code()
+	}
+	return status.Code(err)
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+
+	if value == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// retryInterceptor retries unary gRPC calls that fail with a retriable status code.
+func retryInterceptor(r retrier) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return r.do(ctx, method, func() error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+	}
+}
+
+// doSearchRequest sends a search request with retries, returning non-200 responses as a *SearchError.
+func (c client) doSearchRequest(httpReq *http.Request) (resp *http.Response, err error) {
+
+	ctx := httpReq.Context()
+	err = c.retrier.do(ctx, httpReq.URL.Path, func() error {
+		attemptReq := httpReq.Clone(ctx)
+		if httpReq.GetBody != nil {
+			body, err := httpReq.GetBody()
+			if err != nil {
+				return err
+			}
+			attemptReq.Body = body
+		}
+
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		r, err := c.searchClient.Do(attemptReq)
+		if err != nil {
+			return err
+		}
+
+		if r.StatusCode != http.StatusOK {
+			defer r.Body.Close()
+			rawB, _ := ioutil.ReadAll(io.LimitReader(r.Body, maxBodyExcerpt))
+			return newSearchError(r, httpReq.URL.Path, rawB)
+		}
+
+		resp = r
+		return nil
+	})
+
+	return
+}
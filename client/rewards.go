@@ -0,0 +1,432 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	"github.com/cosmos/cosmos-sdk/types/query"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	distrTypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	"github.com/figment-networks/indexing-engine/structs"
+	"google.golang.org/grpc/metadata"
+)
+
+// Supported values for Config.RewardsSource. An empty source behaves like RewardsSourceSearch.
+const (
+	RewardsSourceSearch = "search"
+	RewardsSourceNode   = "node"
+)
+
+// commissionPrecision is the scale of a commission rate's integer value, 10^18.
+var commissionPrecision = new(big.Int).Exp(big.NewInt(10), big.NewInt(sdk.Precision), nil)
+
+type RewardsReq struct {
+	Network   string    `json:"network"`
+	ChainID   string    `json:"chain_id"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Account   string    `json:"account"`
+}
+
+// RewardEntry is an amount of rewards earned from a single validator before commission and the commission
+// the validator charged on it. The height and time are those at which the rewards were credited; the
+// height is zero if the source doesn't provide one.
+type RewardEntry struct {
+	Validator string
+	Height    uint64
+	Time      time.Time
+	Amount    Coins
+	Fee       Coins
+}
+
+// GetRewards returns the rewards credited in the requested range, each with its fee.
+func (c client) GetRewards(ctx context.Context, req RewardsReq) (entries []RewardEntry, err error) {
+
+	if c.rewardsSource == RewardsSourceNode {
+		entries, err = c.nodeRewardEntries(ctx, req)
+	} else {
+		entries, err = c.searchRewardEntries(ctx, req)
+	}
+	if err != nil {
+		return
+	}
+
+	for i, entry := range entries {
+
+		// Fees are charged at the commission rate in effect when the rewards were earned, which
+		// differs from the current rate whenever the validator has changed it since.
+		height := entry.Height
+		if height == 0 {
+			height, err = c.creditHeight(ctx, req, entry.Time)
+			if err != nil {
+				return nil, fmt.Errorf("could not get height for rewards at %s: %w", entry.Time, err)
+			}
+		}
+
+		var comm validatorCommission
+		comm, err = c.commissionAt(ctx, entry.Validator, height)
+		if err != nil {
+			return nil, fmt.Errorf("could not get commission of validator %s at height %d: %w", entry.Validator, height, err)
+		}
+
+		// The search source reports rewards before commission. Chain state only holds what is left for the
+		// delegator, so the node source's rewards before commission are derived from that.
+		if c.rewardsSource == RewardsSourceNode {
+			entries[i].Amount, entries[i].Fee = grossFromNet(entry.Amount, comm.value)
+		} else {
+			entries[i].Fee = feeOnGross(entry.Amount, comm.value)
+		}
+	}
+
+	return entries, nil
+}
+
+// feeOnGross returns the commission charged at the rate on rewards before commission. Commission is taken
+// from every denom the validator is rewarded in.
+func feeOnGross(gross Coins, rate *big.Int) (fee Coins) {
+
+	fee = Coins{}
+	for denom, amount := range gross {
+		fee.Add(denom, new(big.Int).Quo(new(big.Int).Mul(amount, rate), commissionPrecision))
+	}
+
+	return fee
+}
+
+// grossFromNet returns the rewards before commission and the commission charged at the rate on rewards of
+// which net was left for the delegator. At a rate of 100% nothing is left to derive them from, so the fee
+// is zero.
+func grossFromNet(net Coins, rate *big.Int) (gross Coins, fee Coins) {
+
+	gross = Coins{}
+	fee = Coins{}
+	remaining := new(big.Int).Sub(commissionPrecision, rate)
+	for denom, amount := range net {
+		denomFee := big.NewInt(0)
+		if remaining.Sign() > 0 {
+			denomFee.Quo(new(big.Int).Mul(amount, rate), remaining)
+		}
+		fee.Add(denom, denomFee)
+		gross.Add(denom, new(big.Int).Add(amount, denomFee))
+	}
+
+	return gross, fee
+}
+
+func (c client) GetRewardsAndFeesSum(ctx context.Context, req RewardsReq) (rewards map[string]Coins, fees map[string]Coins, err error) {
+
+	entries, err := c.GetRewards(ctx, req)
+	if err != nil {
+		return
+	}
+
+	rewards, fees = SumRewards(entries)
+	return rewards, fees, nil
+}
+
+// SumRewards adds up the rewards and fees of the entries by validator.
+func SumRewards(entries []RewardEntry) (rewards map[string]Coins, fees map[string]Coins) {
+
+	rewards = map[string]Coins{}
+	fees = map[string]Coins{}
+	for _, entry := range entries {
+
+		// Create or add to the running total for this validator.
+		if _, ok := rewards[entry.Validator]; !ok {
+			rewards[entry.Validator] = Coins{}
+			fees[entry.Validator] = Coins{}
+		}
+		rewards[entry.Validator].AddCoins(entry.Amount)
+		fees[entry.Validator].AddCoins(entry.Fee)
+	}
+
+	return rewards, fees
+}
+
+// nodeRewardEntries derives the rewards earned in a period from chain state alone. Rewards earned are the
+// rewards withdrawn during the period, whether explicitly or automatically by a delegation change, plus the
+// change in outstanding rewards between the last heights before the period's start and end.
+func (c client) nodeRewardEntries(ctx context.Context, req RewardsReq) (entries []RewardEntry, err error) {
+
+	startHeight, err := c.GetLastHeightBefore(ctx, LastHeightBeforeReq{
+		Network:    req.Network,
+		ChainID:    req.ChainID,
+		BeforeTime: req.StartTime,
+	})
+	if errors.Is(err, ErrNoHeights) {
+		// The period starts before the archive's first block. A chain started from an exported genesis, such
+		// as cosmoshub-4, carries over the rewards outstanding on the previous chain, whose segment already
+		// counts them as earned, so the rewards outstanding at the first block are taken as the start.
+		startHeight, err = c.earliestHeight(ctx)
+	}
+	if err != nil {
+		return
+	}
+
+	endHeight, err := c.GetLastHeightBefore(ctx, LastHeightBeforeReq{
+		Network:    req.Network,
+		ChainID:    req.ChainID,
+		BeforeTime: req.EndTime,
+	})
+	if err != nil {
+		return
+	}
+
+	startPending, err := c.getPendingRewards(ctx, req.Account, startHeight)
+	if err != nil {
+		return
+	}
+
+	endPending, err := c.getPendingRewards(ctx, req.Account, endHeight)
+	if err != nil {
+		return
+	}
+
+	entries, err = c.getWithdrawnRewards(ctx, req.Account, startHeight, endHeight)
+	if err != nil {
+		return
+	}
+
+	// The change in outstanding rewards is credited by the end of the period, at its last instant rather
+	// than at the exclusive end, which is already the next period.
+	creditedAt := req.EndTime.Add(-time.Nanosecond)
+
+	for v, amount := range endPending {
+		delta := Coins{}
+		delta.AddCoins(amount)
+		for denom, start := range startPending[v] {
+			delta.Add(denom, new(big.Int).Neg(start))
+		}
+		entries = append(entries, RewardEntry{Validator: v, Height: endHeight, Time: creditedAt, Amount: delta})
+	}
+
+	// Validators no longer delegated to by the end of the period had their outstanding rewards withdrawn
+	// during it. The part outstanding at the start was earned in an earlier period.
+	for v, amount := range startPending {
+		if _, ok := endPending[v]; !ok {
+			delta := Coins{}
+			for denom, start := range amount {
+				delta.Add(denom, new(big.Int).Neg(start))
+			}
+			entries = append(entries, RewardEntry{
+				Validator: v,
+				Height:    endHeight,
+				Time:      creditedAt,
+				Amount:    delta,
+			})
+		}
+	}
+
+	return entries, nil
+}
+
+// GetDelegationRewards returns the account's outstanding rewards by validator at the height.
+func (c *client) GetDelegationRewards(
+	ctx context.Context,
+	params structs.HeightAccount,
+) (pending map[string]Coins, err error) {
+	return c.getPendingRewards(ctx, params.Account, params.Height)
+}
+
+// getPendingRewards returns the outstanding rewards by validator at the given height, truncated to whole
+// base units as they would be when withdrawn.
+func (c client) getPendingRewards(ctx context.Context, account string, height uint64) (pending map[string]Coins, err error) {
+
+	resp, err := c.distributionClient.DelegationTotalRewards(
+		metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatUint(height, 10)),
+		&distrTypes.QueryDelegationTotalRewardsRequest{DelegatorAddress: account},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("[COSMOS-API] Error fetching delegation rewards: %w", err)
+	}
+
+	pending = map[string]Coins{}
+	for _, r := range resp.Rewards {
+		coins, _ := r.Reward.TruncateDecimal()
+		pending[r.ValidatorAddress] = Coins{}
+		for _, coin := range coins {
+			pending[r.ValidatorAddress].Add(coin.Denom, coin.Amount.BigInt())
+		}
+	}
+
+	return pending, nil
+}
+
+// getWithdrawnRewards finds all rewards withdrawn by the account in the height range (fromHeight, toHeight].
+// Withdrawals are found in the withdraw_rewards events of the account's transactions, which includes
+// those emitted when a delegation change automatically withdraws rewards.
+func (c client) getWithdrawnRewards(ctx context.Context, account string, fromHeight, toHeight uint64) (entries []RewardEntry, err error) {
+
+	if toHeight <= fromHeight {
+		return
+	}
+
+	heights := []string{
+		fmt.Sprintf("tx.height>=%d", fromHeight+1),
+		fmt.Sprintf("tx.height<=%d", toHeight),
+	}
+
+	// Withdrawals executed on the account's behalf, such as through an authz grant, are sent by the
+	// grantee. Chains recording the delegator in withdraw_rewards events find those by it.
+	filters := []string{
+		fmt.Sprintf("message.sender='%s'", account),
+		fmt.Sprintf("%s.delegator='%s'", distrTypes.EventTypeWithdrawRewards, account),
+	}
+
+	seen := map[string]bool{}
+	for _, filter := range filters {
+		txs, err := c.searchTxs(ctx, append([]string{filter}, heights...))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, txResp := range txs {
+			if seen[txResp.TxHash] {
+				continue
+			}
+			seen[txResp.TxHash] = true
+
+			txEntries, err := withdrawEntriesFromTx(txResp, account)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, txEntries...)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Height < entries[j].Height
+	})
+
+	return entries, nil
+}
+
+// searchTxs returns every transaction matching the events, in ascending height order.
+func (c client) searchTxs(ctx context.Context, events []string) (txs []*sdk.TxResponse, err error) {
+
+	var offset uint64
+	for {
+		resp, err := c.txClient.GetTxsEvent(ctx, &tx.GetTxsEventRequest{
+			Events:     events,
+			Pagination: &query.PageRequest{Offset: offset, Limit: c.pageSize},
+			OrderBy:    tx.OrderBy_ORDER_BY_ASC,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("[COSMOS-API] Error searching transactions: %w", err)
+		}
+
+		txs = append(txs, resp.TxResponses...)
+
+		offset += uint64(len(resp.TxResponses))
+		if len(resp.TxResponses) == 0 || resp.Pagination == nil || offset >= resp.Pagination.Total {
+			break
+		}
+	}
+
+	return txs, nil
+}
+
+// withdrawal is a single withdrawal of a withdraw_rewards event. The delegator is only recorded by newer
+// chains.
+type withdrawal struct {
+	amount    string
+	validator string
+	delegator string
+}
+
+func withdrawEntriesFromTx(txResp *sdk.TxResponse, account string) (entries []RewardEntry, err error) {
+
+	// Failed transactions don't withdraw anything.
+	if txResp.Code != 0 {
+		return
+	}
+
+	txTime, err := time.Parse(time.RFC3339, txResp.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse timestamp of tx %s: %w", txResp.TxHash, err)
+	}
+
+	for _, log := range txResp.Logs {
+		sentByAccount := logHasSender(log, account)
+
+		for _, ev := range log.Events {
+			if ev.Type != distrTypes.EventTypeWithdrawRewards {
+				continue
+			}
+
+			for _, w := range withdrawals(ev) {
+				// Without a delegator the withdrawal belongs to the message's sender, so withdrawals
+				// for other delegators in the same transaction are ignored.
+				if w.delegator != account && (w.delegator != "" || !sentByAccount) {
+					continue
+				}
+
+				entry := RewardEntry{
+					Validator: w.validator,
+					Height:    uint64(txResp.Height),
+					Time:      txTime,
+					Amount:    Coins{},
+				}
+
+				// An empty amount means there was nothing to withdraw.
+				if w.amount != "" {
+					coins, err := sdk.ParseCoinsNormalized(w.amount)
+					if err != nil {
+						return nil, fmt.Errorf("could not parse withdrawn amount in tx %s: %w", txResp.TxHash, err)
+					}
+					for _, coin := range coins {
+						entry.Amount.Add(coin.Denom, coin.Amount.BigInt())
+					}
+				}
+
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// withdrawals splits a withdraw_rewards event into its withdrawals. Events of the same type within a
+// message are merged, so each withdrawal's attributes follow the previous one's, starting with the amount.
+func withdrawals(ev sdk.StringEvent) (ws []withdrawal) {
+	for _, attr := range ev.Attributes {
+		switch attr.Key {
+		case sdk.AttributeKeyAmount:
+			ws = append(ws, withdrawal{amount: attr.Value})
+		case distrTypes.AttributeKeyValidator:
+			if len(ws) == 0 {
+				ws = append(ws, withdrawal{})
+			}
+			ws[len(ws)-1].validator = attr.Value
+		case "delegator":
+			if len(ws) == 0 {
+				ws = append(ws, withdrawal{})
+			}
+			ws[len(ws)-1].delegator = attr.Value
+		}
+	}
+	return ws
+}
+
+// logHasSender reports whether the message the log belongs to was sent by the account.
+func logHasSender(log sdk.ABCIMessageLog, account string) bool {
+	for _, ev := range log.Events {
+		if ev.Type != sdk.EventTypeMessage {
+			continue
+		}
+		for _, attr := range ev.Attributes {
+			if attr.Key == sdk.AttributeKeySender && attr.Value == account {
+				return true
+			}
+		}
+	}
+	return false
+}
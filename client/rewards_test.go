@@ -0,0 +1,253 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	distrTypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var testGenesis = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testBlockTime is the time of the block at the height on every test archive, a block a minute.
+func testBlockTime(height uint64) time.Time {
+	return testGenesis.Add(time.Duration(height) * time.Minute)
+}
+
+// fakeArchive serves blocks from first to latest and the account's outstanding uatom rewards from a
+// single validator by height. It has no transactions.
+type fakeArchive struct {
+	tmservice.ServiceClient
+	distrTypes.QueryClient
+
+	first, latest uint64
+	pending       map[uint64]int64
+	// latestQueries counts the requests for the latest block.
+	latestQueries int
+}
+
+func (a *fakeArchive) client() client {
+	return client{
+		tmClient:           a,
+		distributionClient: a,
+		txClient:           noTxs{},
+		heightSource:       HeightSourceNode,
+		heightCache:        newHeightCache(),
+		commissionCache:    newCommissionCache(),
+		bondDenomCache:     newBondDenomCache(),
+		rewardsSource:      RewardsSourceNode,
+		pageSize:           100,
+	}
+}
+
+func (a *fakeArchive) block(height uint64) *tmproto.Block {
+	return &tmproto.Block{Header: tmproto.Header{Height: int64(height), Time: testBlockTime(height)}}
+}
+
+func (a *fakeArchive) GetLatestBlock(ctx context.Context, in *tmservice.GetLatestBlockRequest, opts ...grpc.CallOption) (*tmservice.GetLatestBlockResponse, error) {
+	a.latestQueries++
+	return &tmservice.GetLatestBlockResponse{Block: a.block(a.latest)}, nil
+}
+
+func (a *fakeArchive) GetBlockByHeight(ctx context.Context, in *tmservice.GetBlockByHeightRequest, opts ...grpc.CallOption) (*tmservice.GetBlockByHeightResponse, error) {
+	h := uint64(in.Height)
+	if h < a.first || h > a.latest {
+		return nil, fmt.Errorf("height %d is not available", h)
+	}
+	return &tmservice.GetBlockByHeightResponse{Block: a.block(h)}, nil
+}
+
+func (a *fakeArchive) DelegationTotalRewards(ctx context.Context, in *distrTypes.QueryDelegationTotalRewardsRequest, opts ...grpc.CallOption) (*distrTypes.QueryDelegationTotalRewardsResponse, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	heights := md.Get(grpctypes.GRPCBlockHeightHeader)
+	if len(heights) != 1 {
+		return nil, fmt.Errorf("no height requested")
+	}
+	h, _ := strconv.ParseUint(heights[0], 10, 64)
+
+	amount, ok := a.pending[h]
+	if !ok {
+		return nil, fmt.Errorf("unexpected pending rewards request at height %d", h)
+	}
+	return &distrTypes.QueryDelegationTotalRewardsResponse{
+		Rewards: []distrTypes.DelegationDelegatorReward{{
+			ValidatorAddress: "cosmosvaloper1test",
+			Reward:           sdk.NewDecCoins(sdk.NewDecCoin("uatom", sdk.NewInt(amount))),
+		}},
+	}, nil
+}
+
+// noTxs is a transaction service without any transactions.
+type noTxs struct {
+	tx.ServiceClient
+}
+
+func (noTxs) GetTxsEvent(ctx context.Context, in *tx.GetTxsEventRequest, opts ...grpc.CallOption) (*tx.GetTxsEventResponse, error) {
+	return &tx.GetTxsEventResponse{}, nil
+}
+
+func TestNodeRewardEntriesGenesisHandover(t *testing.T) {
+
+	ctx := context.Background()
+
+	// The old chain halts at height 100 with 50uatom outstanding. The new one starts at height 101 from
+	// its exported genesis, which carries those 50uatom over.
+	old := &fakeArchive{first: 1, latest: 100, pending: map[uint64]int64{10: 5, 100: 50}}
+	upgraded := &fakeArchive{first: 101, latest: 300, pending: map[uint64]int64{101: 51, 200: 80}}
+
+	// The report splits a period spanning the upgrade at the segments' bounds.
+	segments := []struct {
+		archive *fakeArchive
+		req     RewardsReq
+	}{
+		{old, RewardsReq{ChainID: "cosmoshub-3", StartTime: testBlockTime(10).Add(30 * time.Second), EndTime: testBlockTime(100).Add(time.Nanosecond)}},
+		{upgraded, RewardsReq{ChainID: "cosmoshub-4", StartTime: testBlockTime(101), EndTime: testBlockTime(200).Add(30 * time.Second)}},
+	}
+
+	earned := int64(0)
+	for _, s := range segments {
+		s.req.Account = "cosmos1test"
+		entries, err := s.archive.client().nodeRewardEntries(ctx, s.req)
+		if err != nil {
+			t.Fatalf("%s: %v", s.req.ChainID, err)
+		}
+		for _, e := range entries {
+			earned += e.Amount["uatom"].Int64()
+		}
+	}
+
+	// 45uatom on the old chain and 29uatom on the new one; the carried over 50uatom only once.
+	if earned != 74 {
+		t.Errorf("earned %duatom over both segments, want 74", earned)
+	}
+}
+
+// fixedCommission is a staking service whose validators always charge the same commission rate.
+type fixedCommission struct {
+	stakingTypes.QueryClient
+
+	rate    sdk.Dec
+	queries int
+}
+
+func (f *fixedCommission) Validator(ctx context.Context, in *stakingTypes.QueryValidatorRequest, opts ...grpc.CallOption) (*stakingTypes.QueryValidatorResponse, error) {
+	f.queries++
+	validator := stakingTypes.Validator{OperatorAddress: in.ValidatorAddr}
+	validator.Commission.Rate = f.rate
+	return &stakingTypes.QueryValidatorResponse{Validator: validator}, nil
+}
+
+func TestNodeRewardsCommission(t *testing.T) {
+
+	// 900uatom accrue to the delegator over the period, what is left of 1000uatom after 10% commission.
+	archive := &fakeArchive{first: 1, latest: 300, pending: map[uint64]int64{10: 0, 100: 900}}
+	c := archive.client()
+	c.stakingClient = &fixedCommission{rate: sdk.NewDecWithPrec(1, 1)}
+
+	entries, err := c.GetRewards(context.Background(), RewardsReq{
+		ChainID:   "cosmoshub-4",
+		Account:   "cosmos1test",
+		StartTime: testBlockTime(10).Add(30 * time.Second),
+		EndTime:   testBlockTime(100).Add(30 * time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rewards, fees := SumRewards(entries)
+	gross, fee := rewards["cosmosvaloper1test"]["uatom"], fees["cosmosvaloper1test"]["uatom"]
+	if gross == nil || fee == nil {
+		t.Fatalf("got rewards %v and fees %v, want uatom from cosmosvaloper1test", rewards, fees)
+	}
+	if gross.Int64() != 1000 || fee.Int64() != 100 {
+		t.Errorf("got %duatom rewards with a %duatom fee, want 1000uatom with a 100uatom fee", gross, fee)
+	}
+	if net := new(big.Int).Sub(gross, fee); net.Int64() != 900 {
+		t.Errorf("got %duatom net rewards, want the 900uatom accrued", net)
+	}
+}
+
+func TestGrossFromNet(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		net       int64
+		rate      sdk.Dec
+		wantGross int64
+		wantFee   int64
+	}{
+		{name: "no commission", net: 900, rate: sdk.ZeroDec(), wantGross: 900, wantFee: 0},
+		{name: "five percent", net: 950, rate: sdk.NewDecWithPrec(5, 2), wantGross: 1000, wantFee: 50},
+		{name: "full commission", net: 0, rate: sdk.OneDec(), wantGross: 0, wantFee: 0},
+		{name: "negative change", net: -900, rate: sdk.NewDecWithPrec(1, 1), wantGross: -1000, wantFee: -100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gross, fee := grossFromNet(Coins{"uatom": big.NewInt(tt.net)}, tt.rate.BigInt())
+			if gross["uatom"].Int64() != tt.wantGross || fee["uatom"].Int64() != tt.wantFee {
+				t.Errorf("got gross %s and fee %s, want %d and %d", gross["uatom"], fee["uatom"], tt.wantGross, tt.wantFee)
+			}
+			if sum := new(big.Int).Sub(gross["uatom"], fee["uatom"]); sum.Int64() != tt.net {
+				t.Errorf("gross minus fee is %s, want the net %d", sum, tt.net)
+			}
+		})
+	}
+}
+
+func TestCommissionLookupsAreShared(t *testing.T) {
+
+	ctx := context.Background()
+
+	archive := &fakeArchive{first: 1, latest: 300, pending: map[uint64]int64{10: 0, 100: 900}}
+	staking := &fixedCommission{rate: sdk.NewDecWithPrec(1, 1)}
+	c := archive.client()
+	c.stakingClient = staking
+
+	// Every account of a report credited at the same height shares its validators' commission.
+	for _, account := range []string{"cosmos1a", "cosmos1b"} {
+		_, err := c.GetRewards(ctx, RewardsReq{
+			ChainID:   "cosmoshub-4",
+			Account:   account,
+			StartTime: testBlockTime(10).Add(30 * time.Second),
+			EndTime:   testBlockTime(100).Add(30 * time.Second),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if staking.queries != 1 {
+		t.Errorf("queried the commission %d times, want once", staking.queries)
+	}
+
+	// Rewards credited on the same day resolve to the same height without searching for it again.
+	req := RewardsReq{ChainID: "cosmoshub-4"}
+	at := testBlockTime(200).Add(30 * time.Second)
+	first, err := c.creditHeight(ctx, req, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := archive.latestQueries
+	second, err := c.creditHeight(ctx, req, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 200 || second != 200 {
+		t.Errorf("got heights %d and %d, want 200", first, second)
+	}
+	if archive.latestQueries != queries {
+		t.Error("searched for the height of the same credit time again")
+	}
+}
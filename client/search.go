@@ -4,12 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io/ioutil"
-	"math/big"
 	"net/http"
 	neturl "net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -33,7 +31,7 @@ type heightResp struct {
 	Height uint64 `json:"height"`
 }
 
-func (c client) GetLastHeightBefore(ctx context.Context, req LastHeightBeforeReq) (height uint64, err error) {
+func (c client) searchLastHeightBefore(ctx context.Context, req LastHeightBeforeReq) (height uint64, err error) {
 
 	searchReq := txSearchReqBody{
 		Network:    req.Network,
@@ -60,18 +58,11 @@ func (c client) GetLastHeightBefore(ctx context.Context, req LastHeightBeforeReq
 	}
 
 	httpReq.Header = http.Header{"Authorization": []string{c.authToken}}
-	resp, err := c.searchClient.Do(httpReq)
+	resp, err := c.doSearchRequest(httpReq)
 	if err != nil {
 		return
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		rawB, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return 0, err
-		}
-		return 0, fmt.Errorf("%w, %s", err, string(rawB))
-	}
+	defer resp.Body.Close()
 
 	var hr []heightResp
 	dec := json.NewDecoder(resp.Body)
@@ -80,24 +71,47 @@ func (c client) GetLastHeightBefore(ctx context.Context, req LastHeightBeforeReq
 	}
 
 	if len(hr) == 0 {
-		err = errors.New("no heights found before time " + req.BeforeTime.String())
+		err = fmt.Errorf("%w before time %s", ErrNoHeights, req.BeforeTime)
 		return
 	}
 
 	return hr[0].Height, nil
 }
 
-type RewardsReq struct {
-	Network   string    `json:"network"`
-	ChainID   string    `json:"chain_id"`
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
-	Account   string    `json:"account"`
-}
+func (c client) searchRewardEntries(ctx context.Context, req RewardsReq) (entries []RewardEntry, err error) {
+
+	// The service may cap the number of summaries it returns below the limit asked for, so keep asking
+	// for the entries after those received until an empty page comes back.
+	limit := int(c.pageSize)
+	var prev []structs.RewardSummary
+	for offset := 0; ; {
+		var page []structs.RewardSummary
+		page, err = c.searchRewardsPage(ctx, req, limit, offset)
+		if err != nil {
+			return
+		}
+
+		// A service that doesn't support paging returns everything at once, or the same page again.
+		if len(page) > limit || samePage(page, prev) {
+			if len(page) > limit {
+				entries = appendRewardEntries(nil, page)
+			}
+			break
+		}
 
-func (c client) GetRewardsAndFeesSum(ctx context.Context, req RewardsReq) (rewards map[string]*big.Int, fees map[string]*big.Int, err error) {
+		if len(page) == 0 {
+			break
+		}
 
-	validatorCommisions := map[string]validatorCommission{}
+		entries = appendRewardEntries(entries, page)
+		offset += len(page)
+		prev = page
+	}
+
+	return entries, nil
+}
+
+func (c client) searchRewardsPage(ctx context.Context, req RewardsReq, limit, offset int) (dailySumm []structs.RewardSummary, err error) {
 
 	url := c.searchAddr
 	if !strings.HasSuffix(url, "/") {
@@ -111,6 +125,8 @@ func (c client) GetRewardsAndFeesSum(ctx context.Context, req RewardsReq) (rewar
 	params.Add("start_time", req.StartTime.Format(time.RFC3339))
 	params.Add("end_time", req.EndTime.Format(time.RFC3339))
 	params.Add("account", req.Account)
+	params.Add("limit", strconv.Itoa(limit))
+	params.Add("offset", strconv.Itoa(offset))
 	url += params.Encode()
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
@@ -119,71 +135,46 @@ func (c client) GetRewardsAndFeesSum(ctx context.Context, req RewardsReq) (rewar
 	}
 
 	httpReq.Header = http.Header{"Authorization": []string{c.authToken}}
-	resp, err := c.searchClient.Do(httpReq)
+	resp, err := c.doSearchRequest(httpReq)
 	if err != nil {
 		return
 	}
+	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		rawB, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, nil, err
-		}
-		return nil, nil, fmt.Errorf("%w, %s", err, string(rawB))
-	}
-
-	var dailySumm []structs.RewardSummary
 	dec := json.NewDecoder(resp.Body)
-	if err = dec.Decode(&dailySumm); err != nil {
-		return
-	}
+	err = dec.Decode(&dailySumm)
 
-	rewards = map[string]*big.Int{}
-	fees = map[string]*big.Int{}
-	for _, entry := range dailySumm {
+	return
+}
 
-		// First sum all amounts present in this entry.
-		entrySubtotal := big.NewInt(0)
-		for _, amount := range entry.Amount {
-			entrySubtotal = entrySubtotal.Add(entrySubtotal, amount.Numeric)
-		}
+func appendRewardEntries(entries []RewardEntry, dailySumm []structs.RewardSummary) []RewardEntry {
 
-		// Then create or add to the running total for this validator.
-		if rew, ok := rewards[string(entry.Validator)]; ok {
-			rewards[string(entry.Validator)] = rew.Add(rew, entrySubtotal)
-		} else {
-			rewards[string(entry.Validator)] = entrySubtotal
-		}
+	for _, entry := range dailySumm {
 
-		comm, ok := validatorCommisions[string(entry.Validator)]
-		if !ok {
-			comm, err = c.getValidatorCommission(ctx, string(entry.Validator))
-			if err != nil {
-				fmt.Printf("error getting validator %s: %s\n", string(entry.Validator), err.Error())
-			}
-			validatorCommisions[string(entry.Validator)] = comm
+		// Sum the amounts present in this entry by denom.
+		entrySubtotal := Coins{}
+		for _, amount := range entry.Amount {
+			entrySubtotal.Add(amount.Currency, amount.Numeric)
 		}
 
-		if comm.lastChanged.After(req.StartTime) {
-			fmt.Printf("validator %s fee last changed on %s\n", entry.Validator, comm.lastChanged.String())
-		}
+		entries = append(entries, RewardEntry{
+			Validator: string(entry.Validator),
+			Height:    entry.End,
+			Time:      entry.Time,
+			Amount:    entrySubtotal,
+		})
+	}
 
-		newFee := big.NewInt(0)
-		// 10^18 is the numerator needed to get the commission rate
-		commNumerator, ok := big.NewInt(0).SetString("1000000000000000000", 10)
-		if !ok {
-			panic("no can numerator")
-		}
+	return entries
+}
 
-		newFee = newFee.Div(big.NewInt(0).Mul(entrySubtotal, comm.value), commNumerator)
+func samePage(a, b []structs.RewardSummary) bool {
 
-		if fee, ok := fees[string(entry.Validator)]; ok {
-			fees[string(entry.Validator)] = fee.Add(fee, newFee)
-		} else {
-			fees[string(entry.Validator)] = newFee
-		}
+	if len(a) == 0 || len(a) != len(b) {
+		return false
 	}
 
-	return rewards, fees, nil
-
+	first, last := len(a)-1, len(b)-1
+	return a[0].Validator == b[0].Validator && a[0].Time.Equal(b[0].Time) &&
+		a[first].Validator == b[last].Validator && a[first].Time.Equal(b[last].Time)
 }
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	"github.com/cosmos/cosmos-sdk/types/query"
+	"github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/figment-networks/indexing-engine/structs"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnbondingDelegation is the balance still unbonding from a validator, summed over all its entries.
+type UnbondingDelegation struct {
+	Validator string
+	Balance   *big.Int
+	Denom     string
+}
+
+// Redelegation is the balance moved from one validator to another that has not completed yet.
+type Redelegation struct {
+	SrcValidator string
+	DstValidator string
+	Balance      *big.Int
+	Denom        string
+}
+
+func (c *client) GetUnbondingDelegations(
+	ctx context.Context,
+	params structs.HeightAccount,
+) (unbondings []UnbondingDelegation, err error) {
+
+	denom, err := c.getBondDenom(ctx, params.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	heightCtx := metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatUint(params.Height, 10))
+
+	var nextKey []byte
+	for {
+		resp, err := c.stakingClient.DelegatorUnbondingDelegations(
+			heightCtx,
+			&types.QueryDelegatorUnbondingDelegationsRequest{
+				DelegatorAddr: params.Account,
+				Pagination:    &query.PageRequest{Key: nextKey, Limit: c.pageSize},
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("[COSMOS-API] Error fetching unbonding delegations: %w", err)
+		}
+
+		for _, ud := range resp.UnbondingResponses {
+			balance := big.NewInt(0)
+			for _, entry := range ud.Entries {
+				balance = balance.Add(balance, entry.Balance.BigInt())
+			}
+			unbondings = append(unbondings, UnbondingDelegation{
+				Validator: ud.ValidatorAddress,
+				Balance:   balance,
+				Denom:     denom,
+			})
+		}
+
+		if resp.Pagination == nil || len(resp.Pagination.NextKey) == 0 {
+			break
+		}
+		nextKey = resp.Pagination.NextKey
+	}
+
+	return unbondings, nil
+}
+
+func (c *client) GetRedelegations(
+	ctx context.Context,
+	params structs.HeightAccount,
+) (redelegations []Redelegation, err error) {
+
+	denom, err := c.getBondDenom(ctx, params.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	heightCtx := metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatUint(params.Height, 10))
+
+	var nextKey []byte
+	for {
+		resp, err := c.stakingClient.Redelegations(
+			heightCtx,
+			&types.QueryRedelegationsRequest{
+				DelegatorAddr: params.Account,
+				Pagination:    &query.PageRequest{Key: nextKey, Limit: c.pageSize},
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("[COSMOS-API] Error fetching redelegations: %w", err)
+		}
+
+		for _, rd := range resp.RedelegationResponses {
+			balance := big.NewInt(0)
+			for _, entry := range rd.Entries {
+				balance = balance.Add(balance, entry.Balance.BigInt())
+			}
+			redelegations = append(redelegations, Redelegation{
+				SrcValidator: rd.Redelegation.ValidatorSrcAddress,
+				DstValidator: rd.Redelegation.ValidatorDstAddress,
+				Balance:      balance,
+				Denom:        denom,
+			})
+		}
+
+		if resp.Pagination == nil || len(resp.Pagination.NextKey) == 0 {
+			break
+		}
+		nextKey = resp.Pagination.NextKey
+	}
+
+	return redelegations, nil
+}
+
+// bondDenomCache holds the staking denom at each height, shared by every call on a client.
+type bondDenomCache struct {
+	lock   sync.RWMutex
+	denoms map[uint64]string
+}
+
+func newBondDenomCache() *bondDenomCache {
+	return &bondDenomCache{denoms: map[uint64]string{}}
+}
+
+// getBondDenom returns the staking denom at the height, querying it only once per client.
+func (c *client) getBondDenom(ctx context.Context, height uint64) (string, error) {
+
+	c.bondDenomCache.lock.RLock()
+	denom, ok := c.bondDenomCache.denoms[height]
+	c.bondDenomCache.lock.RUnlock()
+	if ok {
+		return denom, nil
+	}
+
+	resp, err := c.stakingClient.Params(
+		metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatUint(height, 10)),
+		&types.QueryParamsRequest{},
+	)
+	if err != nil {
+		return "", fmt.Errorf("[COSMOS-API] Error fetching staking params: %w", err)
+	}
+
+	c.bondDenomCache.lock.Lock()
+	c.bondDenomCache.denoms[height] = resp.Params.BondDenom
+	c.bondDenomCache.lock.Unlock()
+
+	return resp.Params.BondDenom, nil
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/figment-networks/indexing-engine/structs"
+	"google.golang.org/grpc"
+)
+
+// unbondingStaking is a staking service with one unbonding delegation per account.
+type unbondingStaking struct {
+	stakingTypes.QueryClient
+
+	// paramsQueries counts the requests for the staking params.
+	paramsQueries int
+}
+
+func (s *unbondingStaking) Params(ctx context.Context, in *stakingTypes.QueryParamsRequest, opts ...grpc.CallOption) (*stakingTypes.QueryParamsResponse, error) {
+	s.paramsQueries++
+	return &stakingTypes.QueryParamsResponse{Params: stakingTypes.Params{BondDenom: "uatom"}}, nil
+}
+
+func (s *unbondingStaking) DelegatorUnbondingDelegations(ctx context.Context, in *stakingTypes.QueryDelegatorUnbondingDelegationsRequest, opts ...grpc.CallOption) (*stakingTypes.QueryDelegatorUnbondingDelegationsResponse, error) {
+	return &stakingTypes.QueryDelegatorUnbondingDelegationsResponse{
+		UnbondingResponses: []stakingTypes.UnbondingDelegation{{
+			DelegatorAddress: in.DelegatorAddr,
+			ValidatorAddress: "cosmosvaloper1a",
+			Entries:          []stakingTypes.UnbondingDelegationEntry{{Balance: sdk.NewInt(5)}, {Balance: sdk.NewInt(7)}},
+		}},
+	}, nil
+}
+
+func (s *unbondingStaking) Redelegations(ctx context.Context, in *stakingTypes.QueryRedelegationsRequest, opts ...grpc.CallOption) (*stakingTypes.QueryRedelegationsResponse, error) {
+	return &stakingTypes.QueryRedelegationsResponse{}, nil
+}
+
+func TestBondDenomIsShared(t *testing.T) {
+
+	staking := &unbondingStaking{}
+	c := &client{stakingClient: staking, bondDenomCache: newBondDenomCache(), pageSize: 100}
+	ctx := context.Background()
+
+	for _, height := range []uint64{10, 20} {
+		for _, account := range []string{"cosmos1a", "cosmos1b"} {
+			params := structs.HeightAccount{Height: height, Account: account}
+
+			unbondings, err := c.GetUnbondingDelegations(ctx, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(unbondings) != 1 || unbondings[0].Denom != "uatom" || unbondings[0].Balance.Int64() != 12 {
+				t.Errorf("unbondings of %s at %d = %+v, want 12uatom", account, height, unbondings)
+			}
+			if _, err := c.GetRedelegations(ctx, params); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if staking.paramsQueries != 2 {
+		t.Errorf("%d staking params queries, want one per height", staking.paramsQueries)
+	}
+}
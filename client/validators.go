@@ -2,26 +2,164 @@ package client
 
 import (
 	"context"
+	"fmt"
 	"math/big"
+	"strconv"
+	"sync"
 	"time"
 
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	"github.com/cosmos/cosmos-sdk/types/query"
 	"github.com/cosmos/cosmos-sdk/x/staking/types"
+	"google.golang.org/grpc/metadata"
 )
 
 type validatorCommission struct {
-	value       *big.Int
-	lastChanged time.Time
+	value *big.Int
 }
 
-func (c *client) getValidatorCommission(ctx context.Context, validator string) (vc validatorCommission, err error) {
+type validatorHeight struct {
+	validator string
+	height    uint64
+}
+
+// commissionCache holds the heights rewards were credited at and the commission of validators at those
+// heights, shared by every call on a client. The search source credits rewards daily, so all accounts and
+// periods of a report look up each day's height once and each validator's commission on it once.
+type commissionCache struct {
+	lock        sync.RWMutex
+	heights     map[creditTime]uint64
+	commissions map[validatorHeight]validatorCommission
+}
+
+type creditTime struct {
+	chainID string
+	at      int64
+}
+
+func newCommissionCache() *commissionCache {
+	return &commissionCache{
+		heights:     map[creditTime]uint64{},
+		commissions: map[validatorHeight]validatorCommission{},
+	}
+}
+
+// creditHeight returns the last height before rewards credited at the time, for sources that don't provide
+// one.
+func (c client) creditHeight(ctx context.Context, req RewardsReq, at time.Time) (height uint64, err error) {
+
+	key := creditTime{chainID: req.ChainID, at: at.UnixNano()}
+
+	c.commissionCache.lock.RLock()
+	height, ok := c.commissionCache.heights[key]
+	c.commissionCache.lock.RUnlock()
+	if ok {
+		return height, nil
+	}
+
+	height, err = c.GetLastHeightBefore(ctx, LastHeightBeforeReq{
+		Network:    req.Network,
+		ChainID:    req.ChainID,
+		BeforeTime: at,
+	})
+	if err != nil {
+		return
+	}
+
+	c.commissionCache.lock.Lock()
+	c.commissionCache.heights[key] = height
+	c.commissionCache.lock.Unlock()
+
+	return height, nil
+}
+
+// commissionAt returns the validator's commission as of the given height, querying it only once per client.
+func (c client) commissionAt(ctx context.Context, validator string, height uint64) (vc validatorCommission, err error) {
+
+	key := validatorHeight{validator: validator, height: height}
 
-	resp, err := c.stakingClient.Validator(ctx, &types.QueryValidatorRequest{ValidatorAddr: validator})
+	c.commissionCache.lock.RLock()
+	vc, ok := c.commissionCache.commissions[key]
+	c.commissionCache.lock.RUnlock()
+	if ok {
+		return vc, nil
+	}
+
+	vc, err = c.getValidatorCommission(ctx, validator, height)
+	if err != nil {
+		return
+	}
+
+	c.commissionCache.lock.Lock()
+	c.commissionCache.commissions[key] = vc
+	c.commissionCache.lock.Unlock()
+
+	return vc, nil
+}
+
+// getValidatorCommission returns the validator's commission as of the given height.
+func (c *client) getValidatorCommission(ctx context.Context, validator string, height uint64) (vc validatorCommission, err error) {
+
+	resp, err := c.stakingClient.Validator(
+		metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatUint(height, 10)),
+		&types.QueryValidatorRequest{ValidatorAddr: validator},
+	)
 	if err != nil {
 		return
 	}
 
 	vc.value = resp.Validator.Commission.Rate.BigInt()
-	vc.lastChanged = resp.Validator.Commission.UpdateTime
 
 	return
 }
+
+// Validator is a validator's moniker, status, jailed flag and commission settings.
+type Validator struct {
+	OperatorAddress         string
+	Moniker                 string
+	Status                  string
+	Jailed                  bool
+	CommissionRate          string
+	CommissionMaxRate       string
+	CommissionMaxChangeRate string
+	CommissionUpdateTime    time.Time
+}
+
+// GetValidators returns every validator as of the given height, the latest when it is zero.
+func (c *client) GetValidators(ctx context.Context, height uint64) (validators []Validator, err error) {
+
+	if height > 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatUint(height, 10))
+	}
+
+	var nextKey []byte
+	for {
+		resp, err := c.stakingClient.Validators(ctx, &types.QueryValidatorsRequest{
+			Pagination: &query.PageRequest{Key: nextKey, Limit: c.pageSize},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("[COSMOS-API] Error fetching validators: %w", err)
+		}
+
+		for _, v := range resp.Validators {
+			rates := v.Commission.CommissionRates
+			validators = append(validators, Validator{
+				OperatorAddress:         v.OperatorAddress,
+				Moniker:                 v.Description.Moniker,
+				Status:                  v.Status.String(),
+				Jailed:                  v.Jailed,
+				CommissionRate:          rates.Rate.String(),
+				CommissionMaxRate:       rates.MaxRate.String(),
+				CommissionMaxChangeRate: rates.MaxChangeRate.String(),
+				CommissionUpdateTime:    v.Commission.UpdateTime,
+			})
+		}
+
+		if resp.Pagination == nil || len(resp.Pagination.NextKey) == 0 {
+			break
+		}
+		nextKey = resp.Pagination.NextKey
+	}
+
+	return validators, nil
+}
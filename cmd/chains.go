@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+)
+
+// Account prefixes of well known chains, keyed by chain ID without its revision number.
+var knownAccountPrefixes = map[string]string{
+	"cosmoshub":                "cosmos",
+	"osmosis":                  "osmo",
+	"juno":                     "juno",
+	"akashnet":                 "akash",
+	"regen":                    "regen",
+	"stargaze":                 "stars",
+	"secret":                   "secret",
+	"core":                     "persistence",
+	"irishub":                  "iaa",
+	"sentinelhub":              "sent",
+	"crypto-org-chain-mainnet": "cro",
+}
+
+type chainConfig struct {
+	Network          string   `json:"network"`
+	ChainID          string   `json:"chain_id"`
+	AccountPrefix    string   `json:"account_prefix"`
+	CosmosGRPCAddr   string   `json:"cosmos_grpc_addr"`
+	CosmosSearchAddr string   `json:"cosmos_search_addr"`
+	Accounts         []string `json:"accounts"`
+	// History lists the chain IDs the chain went through when it was upgraded, with the archive
+	// serving each. It is only needed for reports reaching back before an upgrade.
+	History []chainSegmentConfig `json:"history"`
+}
+
+type chainSegmentConfig struct {
+	ChainID          string    `json:"chain_id"`
+	StartHeight      uint64    `json:"start_height"`
+	EndHeight        uint64    `json:"end_height"`
+	StartTime        time.Time `json:"start_time"`
+	EndTime          time.Time `json:"end_time"`
+	CosmosGRPCAddr   string    `json:"cosmos_grpc_addr"`
+	CosmosSearchAddr string    `json:"cosmos_search_addr"`
+}
+
+// chains returns the chains to report on. Without a chains list the top level settings describe the only chain.
+func (c config) chains() []chainConfig {
+
+	if len(c.Chains) == 0 {
+		chainID := c.ChainID
+		if chainID == "" {
+			chainID = "cosmoshub-4"
+		}
+
+		return []chainConfig{c.withChainDefaults(chainConfig{
+			Network:          c.Network,
+			ChainID:          chainID,
+			AccountPrefix:    c.AccountPrefix,
+			CosmosGRPCAddr:   c.CosmosGRPCAddr,
+			CosmosSearchAddr: c.CosmosSearchAddr,
+			Accounts:         c.Accounts,
+		})}
+	}
+
+	chains := make([]chainConfig, len(c.Chains))
+	for i, chain := range c.Chains {
+		chains[i] = c.withChainDefaults(chain)
+	}
+
+	return chains
+}
+
+func (c config) withChainDefaults(chain chainConfig) chainConfig {
+
+	if chain.Network == "" {
+		chain.Network = c.Network
+	}
+	if chain.Network == "" {
+		chain.Network = "cosmos"
+	}
+	if chain.AccountPrefix == "" {
+		chain.AccountPrefix = knownAccountPrefix(chain.ChainID)
+	}
+
+	return chain
+}
+
+func knownAccountPrefix(chainID string) string {
+
+	// Chain IDs are usually of the form <name>-<revision>.
+	name := chainID
+	if i := strings.LastIndex(chainID, "-"); i > 0 {
+		name = chainID[:i]
+	}
+
+	if prefix, ok := knownAccountPrefixes[name]; ok {
+		return prefix
+	}
+
+	return knownAccountPrefixes[chainID]
+}
+
+func (chain chainConfig) validateConnection(usesSearch bool) error {
+
+	if len(chain.History) == 0 {
+		if chain.CosmosGRPCAddr == "" {
+			return errors.New("cosmos grpc address is not set")
+		}
+
+		if usesSearch && chain.CosmosSearchAddr == "" {
+			return errors.New("cosmos search address is not set")
+		}
+	}
+
+	for _, seg := range chain.History {
+		if err := seg.validate(usesSearch); err != nil {
+			return fmt.Errorf("history %s: %w", seg.ChainID, err)
+		}
+	}
+
+	return nil
+}
+
+// grpcAddrs returns the gRPC addresses the chain is queried at.
+func (chain chainConfig) grpcAddrs() []string {
+
+	if len(chain.History) == 0 {
+		return []string{chain.CosmosGRPCAddr}
+	}
+
+	addrs := make([]string, len(chain.History))
+	for i, seg := range chain.History {
+		addrs[i] = seg.CosmosGRPCAddr
+	}
+
+	return addrs
+}
+
+func (chain chainConfig) validateAccounts() error {
+
+	if len(chain.Accounts) == 0 {
+		return errors.New("at least one account must be provided")
+	}
+
+	for _, acc := range chain.Accounts {
+		if err := chain.validateAccount(acc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (chain chainConfig) validateAccount(acc string) error {
+
+	if chain.AccountPrefix == "" {
+		return fmt.Errorf("account prefix of chain %s is unknown and must be set", chain.ChainID)
+	}
+
+	prefix, _, err := bech32.DecodeAndConvert(acc)
+	if err != nil {
+		return fmt.Errorf("invalid account %s: %w", acc, err)
+	}
+	if prefix != chain.AccountPrefix {
+		return fmt.Errorf("account %s does not belong to chain %s, expected prefix %s", acc, chain.ChainID, chain.AccountPrefix)
+	}
+
+	return nil
+}
+
+// outputPath returns the report path for the chain. When a run covers several chains the chain ID is
+// added to the file name so each chain gets its own report.
+func (chain chainConfig) outputPath(path string, numChains int) string {
+
+	if numChains <= 1 {
+		return path
+	}
+
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + chain.ChainID + ext
+}
+
+// endpointAt returns the segment serving the height, the latest one when it is zero.
+func (chain chainConfig) endpointAt(height uint64) (chainSegmentConfig, error) {
+
+	if len(chain.History) == 0 {
+		return chainSegmentConfig{
+			ChainID:          chain.ChainID,
+			CosmosGRPCAddr:   chain.CosmosGRPCAddr,
+			CosmosSearchAddr: chain.CosmosSearchAddr,
+		}, nil
+	}
+
+	if height == 0 {
+		return chain.History[len(chain.History)-1], nil
+	}
+
+	for _, seg := range chain.History {
+		if seg.coversHeight(height) {
+			return seg, nil
+		}
+	}
+
+	return chainSegmentConfig{}, fmt.Errorf("no segment of the history of %s has height bounds covering height %d", chain.ChainID, height)
+}
+
+// coversHeight reports whether the segment's height bounds, if it has any, include the height.
+func (seg chainSegmentConfig) coversHeight(height uint64) bool {
+	if seg.StartHeight == 0 && seg.EndHeight == 0 {
+		return false
+	}
+	return seg.StartHeight <= height && (seg.EndHeight == 0 || height <= seg.EndHeight)
+}
+
+func (seg chainSegmentConfig) validate(usesSearch bool) error {
+
+	if seg.ChainID == "" {
+		return errors.New("chain id is not set")
+	}
+
+	if seg.CosmosGRPCAddr == "" {
+		return errors.New("cosmos grpc address is not set")
+	}
+
+	if usesSearch && seg.CosmosSearchAddr == "" {
+		return errors.New("cosmos search address is not set")
+	}
+
+	if seg.EndHeight > 0 && seg.StartHeight > seg.EndHeight {
+		return errors.New("start height must come before end height")
+	}
+
+	if !seg.EndTime.IsZero() && seg.StartTime.After(seg.EndTime) {
+		return errors.New("start time must come before end time")
+	}
+
+	return nil
+}
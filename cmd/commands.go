@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/figment-networks/cosmos-extract/client"
+	"github.com/figment-networks/cosmos-extract/report"
+	"github.com/figment-networks/cosmos-worker/cmd/common/logger"
+	"github.com/figment-networks/indexing-engine/structs"
+)
+
+type command struct {
+	name  string
+	usage string
+	// run runs the command with the arguments following its name and returns the exit code.
+	run func(args []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"report", "Run the full report (default)", runReport},
+		{"heights", "Print the last height of every period", runHeights},
+		{"delegations", "Print an account's delegations at a height or time", runDelegations},
+		{"rewards", "Print the rewards an account was credited over a time range", runRewards},
+		{"validators", "Print every validator's moniker, status, jailed flag and commission", runValidators},
+		{"config", "Check the config with: config validate", runConfig},
+		{"diff", "Compare two reports or checkpointed runs", runDiff},
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: cosmos-extract <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run cosmos-extract <command> -h for the flags of a command. Flags override the config file and environment.")
+}
+
+// outputFlags are the flags of commands that print a table.
+type outputFlags struct {
+	out    *string
+	format *string
+}
+
+func addOutputFlags(fs *flag.FlagSet) outputFlags {
+	return outputFlags{
+		out:    fs.String("out", "", "Path to write to, stdout when empty"),
+		format: fs.String("format", "", "Output format: csv, json or ndjson. Picked from -out's extension when empty"),
+	}
+}
+
+func (f outputFlags) write(t report.Table) error {
+	return writeOutput(*f.out, *f.format, t)
+}
+
+func runHeights(args []string) int {
+
+	fs := flag.NewFlagSet("heights", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config")
+	out := addOutputFlags(fs)
+	overrides := &configOverrides{}
+	overrides.connection(fs)
+	overrides.periods(fs)
+
+	cfg, ok := loadConfig(fs, args, configPath, overrides)
+	if !ok {
+		return 2
+	}
+
+	return runChainCommand(cfg, func(ctx context.Context, chain chainConfig) error {
+
+		if err := cfg.validatePeriods(); err != nil {
+			return err
+		}
+
+		runner, reportConfig, closeClients, err := newChainRunner(ctx, cfg, client.NewLimiter(cfg.RequestsPerSecond), chain, 1)
+		if err != nil {
+			return err
+		}
+		defer closeClients()
+
+		heights, err := runner.Heights(ctx, reportConfig)
+		if err != nil {
+			return err
+		}
+
+		t := report.Table{Headers: []string{"period", "start_time", "end_time", "chain_id", "end_height"}}
+		for _, h := range heights {
+			t.Rows = append(t.Rows, []string{
+				h.Label,
+				h.StartTime.Format(time.RFC3339),
+				h.EndTime.Format(time.RFC3339),
+				h.ChainID,
+				strconv.FormatUint(h.EndHeight, 10),
+			})
+		}
+
+		return out.write(t)
+	})
+}
+
+func runDelegations(args []string) int {
+
+	fs := flag.NewFlagSet("delegations", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config")
+	account := fs.String("account", "", "Account to get the delegations of")
+	height := fs.Uint64("height", 0, "Height to get the delegations at")
+	at := fs.String("time", "", "Time to get the delegations at, as RFC 3339 or a date, when no height is given. The latest height when neither is")
+	out := addOutputFlags(fs)
+	overrides := &configOverrides{}
+	overrides.connection(fs)
+
+	cfg, ok := loadConfig(fs, args, configPath, overrides)
+	if !ok {
+		return 2
+	}
+
+	return runChainCommand(cfg, func(ctx context.Context, chain chainConfig) error {
+
+		if err := chain.validateAccount(*account); err != nil {
+			return err
+		}
+
+		var atTime time.Time
+		if *at != "" {
+			var err error
+			if atTime, err = parseFlagTime(*at); err != nil {
+				return fmt.Errorf("invalid -time: %w", err)
+			}
+		}
+
+		limiter := client.NewLimiter(cfg.RequestsPerSecond)
+
+		var c client.Client
+		var chainID string
+		h := *height
+		if h == 0 && !atTime.IsZero() {
+			// A time is routed through the chain's history like the periods of a report are.
+			runner, reportConfig, closeClients, err := newChainRunner(ctx, cfg, limiter, chain, 1)
+			if err != nil {
+				return err
+			}
+			defer closeClients()
+
+			// The delegations at a time are those after the last block before it.
+			var seg report.Segment
+			seg, h, err = runner.LastHeightBefore(ctx, reportConfig, atTime.Add(time.Nanosecond))
+			if err != nil {
+				return fmt.Errorf("could not get height at %s: %w", atTime, err)
+			}
+			c, chainID = seg.Client, seg.ChainID
+		} else {
+			endpoint, err := chain.endpointAt(h)
+			if err != nil {
+				return err
+			}
+			if c, err = newClient(ctx, cfg, limiter, endpoint.CosmosGRPCAddr, endpoint.CosmosSearchAddr); err != nil {
+				return err
+			}
+			defer c.Close()
+			chainID = endpoint.ChainID
+
+			// The latest height is resolved so the delegations printed are pinned to it.
+			if h == 0 {
+				if h, err = c.GetLatestHeight(ctx); err != nil {
+					return fmt.Errorf("could not get latest height: %w", err)
+				}
+			}
+		}
+
+		params := structs.HeightAccount{Height: h, Account: *account, Network: chain.Network, ChainID: chainID}
+
+		delegations, err := c.GetAccountDelegations(ctx, params)
+		if err != nil {
+			return fmt.Errorf("could not get account delegations for %+v: %w", params, err)
+		}
+		unbondings, err := c.GetUnbondingDelegations(ctx, params)
+		if err != nil {
+			return fmt.Errorf("could not get unbonding delegations for %+v: %w", params, err)
+		}
+		redelegations, err := c.GetRedelegations(ctx, params)
+		if err != nil {
+			return fmt.Errorf("could not get redelegations for %+v: %w", params, err)
+		}
+
+		heightString := strconv.FormatUint(h, 10)
+		t := report.Table{Headers: []string{"account", "height", "kind", "validator", "dst_validator", "denom", "amount"}}
+		for _, d := range delegations.Delegations {
+			t.Rows = append(t.Rows, []string{*account, heightString, "delegation", string(d.Validator), "", d.Balance.Currency, d.Balance.Numeric.String()})
+		}
+		for _, u := range unbondings {
+			t.Rows = append(t.Rows, []string{*account, heightString, "unbonding", u.Validator, "", u.Denom, u.Balance.String()})
+		}
+		for _, rd := range redelegations {
+			t.Rows = append(t.Rows, []string{*account, heightString, "redelegation", rd.SrcValidator, rd.DstValidator, rd.Denom, rd.Balance.String()})
+		}
+
+		return out.write(t)
+	})
+}
+
+func runRewards(args []string) int {
+
+	fs := flag.NewFlagSet("rewards", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config")
+	account := fs.String("account", "", "Account to get the rewards of")
+	out := addOutputFlags(fs)
+	overrides := &configOverrides{}
+	overrides.connection(fs)
+	overrides.periods(fs)
+
+	cfg, ok := loadConfig(fs, args, configPath, overrides)
+	if !ok {
+		return 2
+	}
+
+	return runChainCommand(cfg, func(ctx context.Context, chain chainConfig) error {
+
+		if err := chain.validateAccount(*account); err != nil {
+			return err
+		}
+		if err := cfg.validatePeriods(); err != nil {
+			return err
+		}
+
+		runner, reportConfig, closeClients, err := newChainRunner(ctx, cfg, client.NewLimiter(cfg.RequestsPerSecond), chain, 1)
+		if err != nil {
+			return err
+		}
+		defer closeClients()
+
+		entries, err := runner.Rewards(ctx, reportConfig, *account)
+		if err != nil {
+			return fmt.Errorf("could not get rewards of %s: %w", *account, err)
+		}
+
+		t := report.Table{Headers: []string{"account", "validator", "height", "time", "denom", "amount", "fee"}}
+		for _, e := range entries {
+			for _, denom := range e.Amount.Denoms() {
+				fee := "0"
+				if f := e.Fee[denom]; f != nil {
+					fee = f.String()
+				}
+				t.Rows = append(t.Rows, []string{
+					*account,
+					e.Validator,
+					strconv.FormatUint(e.Height, 10),
+					e.Time.Format(time.RFC3339),
+					denom,
+					e.Amount[denom].String(),
+					fee,
+				})
+			}
+		}
+
+		return out.write(t)
+	})
+}
+
+func runValidators(args []string) int {
+
+	fs := flag.NewFlagSet("validators", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config")
+	height := fs.Uint64("height", 0, "Height to get the validators at, the latest when zero")
+	out := addOutputFlags(fs)
+	overrides := &configOverrides{}
+	overrides.connection(fs)
+
+	cfg, ok := loadConfig(fs, args, configPath, overrides)
+	if !ok {
+		return 2
+	}
+
+	return runChainCommand(cfg, func(ctx context.Context, chain chainConfig) error {
+
+		endpoint, err := chain.endpointAt(*height)
+		if err != nil {
+			return err
+		}
+		c, err := newClient(ctx, cfg, client.NewLimiter(cfg.RequestsPerSecond), endpoint.CosmosGRPCAddr, endpoint.CosmosSearchAddr)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		validators, err := c.GetValidators(ctx, *height)
+		if err != nil {
+			return fmt.Errorf("could not get validators: %w", err)
+		}
+
+		t := report.Table{Headers: []string{
+			"operator_address", "moniker", "status", "jailed",
+			"commission_rate", "commission_max_rate", "commission_max_change_rate", "commission_update_time",
+		}}
+		for _, v := range validators {
+			t.Rows = append(t.Rows, []string{
+				v.OperatorAddress,
+				v.Moniker,
+				v.Status,
+				strconv.FormatBool(v.Jailed),
+				v.CommissionRate,
+				v.CommissionMaxRate,
+				v.CommissionMaxChangeRate,
+				v.CommissionUpdateTime.Format(time.RFC3339),
+			})
+		}
+
+		return out.write(t)
+	})
+}
+
+func runConfig(args []string) int {
+
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "usage: cosmos-extract config validate [flags]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config")
+	overrides := &configOverrides{}
+	overrides.connection(fs)
+	overrides.periods(fs)
+	overrides.output(fs)
+
+	cfg, ok := loadConfig(fs, args[1:], configPath, overrides)
+	if !ok {
+		return 2
+	}
+
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "config is invalid: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintln(os.Stderr, "config is valid")
+	return 0
+}
+
+// runChainCommand runs a command querying a single chain, logging its error.
+func runChainCommand(cfg *config, run func(ctx context.Context, chain chainConfig) error) int {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger.Init("console", "info", []string{"stderr"})
+	defer logger.Sync()
+
+	err := cfg.validateConnection()
+	if err == nil {
+		var chain chainConfig
+		if chain, err = cfg.chain(); err == nil {
+			err = run(ctx, chain)
+		}
+	}
+	if err != nil {
+		logger.Error(err)
+		return 1
+	}
+
+	return 0
+}
+
+// writeOutput writes the table to the path, or to stdout when it is empty.
+func writeOutput(path, format string, t report.Table) error {
+
+	if format == "" && path != "" {
+		format = report.FormatFromPath(path)
+	}
+
+	if path == "" {
+		return writeOutputTable(os.Stdout, format, t)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := writeOutputTable(f, format, t); err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+
+	return f.Close()
+}
+
+func writeOutputTable(w io.Writer, format string, t report.Table) error {
+
+	bw := bufio.NewWriter(w)
+	if err := report.WriteTable(bw, format, t); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
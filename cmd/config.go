@@ -3,37 +3,88 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/figment-networks/cosmos-extract/client"
+	"github.com/figment-networks/cosmos-extract/pricing"
+	"github.com/figment-networks/cosmos-extract/report"
 	"github.com/kelseyhightower/envconfig"
 )
 
+// Supported values for config.PriceSource. Without a price source no fiat values are reported.
+const (
+	priceSourceFile = "file"
+	priceSourceHTTP = "http"
+)
+
 type config struct {
 	AuthToken              string        `json:"auth_token" envconfig:"AUTH_TOKEN"`
 	CosmosGRPCAddr         string        `json:"cosmos_grpc_addr" envconfig:"COSMOS_GRPC_ADDR"`
 	CosmosSearchAddr       string        `json:"cosmos_search_addr" envconfig:"COSMOS_SEARCH_ADDR"`
+	Network                string        `json:"network" envconfig:"NETWORK" default:"cosmos"`
+	ChainID                string        `json:"chain_id" envconfig:"CHAIN_ID" default:"cosmoshub-4"`
+	AccountPrefix          string        `json:"account_prefix" envconfig:"ACCOUNT_PREFIX"`
+	Chains                 []chainConfig `json:"chains" ignored:"true"`
 	GrpcMaxRecvSize        int           `json:"grpc_max_recv_size" envconfig:"GRPC_MAX_RECV_SIZE" default:"1073741824"` // 1024^3
 	GrpcMaxSendSize        int           `json:"grpc_max_send_size" envconfig:"GRPC_MAX_SEND_SIZE" default:"1073741824"` // 1024^3
 	TLSMode                string        `json:"tls_mode" envconfig:"TLS_MODE" default:""`
+	TLSCAFile              string        `json:"tls_ca_file" envconfig:"TLS_CA_FILE"`
+	TLSCertFile            string        `json:"tls_cert_file" envconfig:"TLS_CERT_FILE"`
+	TLSKeyFile             string        `json:"tls_key_file" envconfig:"TLS_KEY_FILE"`
+	HeightSource           string        `json:"height_source" envconfig:"HEIGHT_SOURCE" default:""`
+	RewardsSource          string        `json:"rewards_source" envconfig:"REWARDS_SOURCE" default:""`
+	Workers                int           `json:"workers" envconfig:"WORKERS" default:"1"`
+	PageSize               uint64        `json:"page_size" envconfig:"PAGE_SIZE" default:"100"`
 	RequestsPerSecond      int           `json:"requests_per_second" envconfig:"REQUESTS_PER_SECOND" default:"33"`
+	RetryMaxAttempts       int           `json:"retry_max_attempts" envconfig:"RETRY_MAX_ATTEMPTS" default:"5"`
+	RetryInitialBackoff    time.Duration `json:"retry_initial_backoff" envconfig:"RETRY_INITIAL_BACKOFF" default:"500ms"`
+	RetryMaxBackoff        time.Duration `json:"retry_max_backoff" envconfig:"RETRY_MAX_BACKOFF" default:"30s"`
+	RetryBudget            time.Duration `json:"retry_budget" envconfig:"RETRY_BUDGET" default:"2m"`
 	TimeoutBlockCall       time.Duration `json:"timeout_block_call" envconfig:"TIMEOUT_BLOCK_CALL" default:"30s"`
 	TimeoutTransactionCall time.Duration `json:"timeout_transaction_call" envconfig:"TIMEOUT_TRANSACTION_CALL" default:"30s"`
+	Granularity            string        `json:"granularity" envconfig:"GRANULARITY" default:"monthly"`
+	PeriodBoundaries       []time.Time   `json:"period_boundaries" envconfig:"PERIOD_BOUNDARIES"`
+	Timezone               string        `json:"timezone" envconfig:"TIMEZONE" default:"UTC"`
 	StartTime              time.Time     `json:"start_time" envconfig:"START_TIME"`
+	PartialPeriods         bool          `json:"partial_periods" envconfig:"PARTIAL_PERIODS"`
 	EndTime                time.Time     `json:"end_time" envconfig:"END_TIME"`
 	Accounts               []string      `json:"accounts" envconfig:"ACCOUNTS"`
 	ReportOutput           string        `json:"report_output" envconfig:"REPORT_OUTPUT" default:"out.csv"`
+	ReportFormat           string        `json:"report_format" envconfig:"REPORT_FORMAT"`
+	ValidatorOrder         string        `json:"validator_order" envconfig:"VALIDATOR_ORDER"`
+	DisplayUnits           bool          `json:"display_units" envconfig:"DISPLAY_UNITS"`
+	DenomUnits             denomUnits    `json:"denom_units" envconfig:"DENOM_UNITS"`
+	PriceSource            string        `json:"price_source" envconfig:"PRICE_SOURCE"`
+	PriceFile              string        `json:"price_file" envconfig:"PRICE_FILE"`
+	PriceAPIAddr           string        `json:"price_api_addr" envconfig:"PRICE_API_ADDR"`
+	PriceAPITimeout        time.Duration `json:"price_api_timeout" envconfig:"PRICE_API_TIMEOUT" default:"30s"`
+	Currency               string        `json:"currency" envconfig:"CURRENCY" default:"USD"`
+	CheckpointPath         string        `json:"checkpoint_path" envconfig:"CHECKPOINT_PATH"`
+	SQLDriver              string        `json:"sql_driver" envconfig:"SQL_DRIVER"`
+	SQLDSN                 string        `json:"sql_dsn" envconfig:"SQL_DSN"`
 }
 
 func initConfig(path string) (*config, error) {
 	cfg := &config{}
+
+	// Fields a config file leaves out get their defaults, as with the environment. Fields it sets keep
+	// the file's value, even a zero one.
+	if err := setDefaults(cfg); err != nil {
+		return nil, err
+	}
+
 	if path != "" {
 		if err := fromFile(path, cfg); err != nil {
 			return nil, err
 		}
 	}
 
-	if cfg.CosmosGRPCAddr != "" {
+	if cfg.CosmosGRPCAddr != "" || len(cfg.Chains) > 0 {
 		return cfg, nil
 	}
 
@@ -56,24 +107,193 @@ func fromEnv(config *config) error {
 	return envconfig.Process("", config)
 }
 
+// setDefaults sets every field to the value of its default tag.
+func setDefaults(cfg *config) error {
+
+	v := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		def, ok := field.Tag.Lookup("default")
+		if !ok || def == "" {
+			continue
+		}
+
+		if err := setDefault(v.Field(i), def); err != nil {
+			return fmt.Errorf("invalid default of %s: %w", field.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func setDefault(f reflect.Value, def string) error {
+
+	if f.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(def)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(def)
+	case reflect.Int:
+		n, err := strconv.ParseInt(def, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(def, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(def)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+
+	return nil
+}
+
 func (c config) validate() error {
 
-	if c.CosmosGRPCAddr == "" {
-		return errors.New("cosmos grpc address is not set")
+	if err := c.validateConnection(); err != nil {
+		return err
+	}
+
+	if _, err := report.NewWriter(c.ReportFormat, c.ReportOutput); err != nil {
+		return err
+	}
+
+	switch c.ValidatorOrder {
+	case "", report.ValidatorOrderAddress, report.ValidatorOrderMoniker:
+	default:
+		return fmt.Errorf("unknown validator order %q", c.ValidatorOrder)
+	}
+
+	switch c.SQLDriver {
+	case "":
+		if c.SQLDSN != "" {
+			return errors.New("sql driver is not set")
+		}
+	case report.SQLDriverSQLite, report.SQLDriverPostgres:
+		if c.SQLDSN == "" {
+			return errors.New("sql dsn is not set")
+		}
+	default:
+		return fmt.Errorf("unknown sql driver %q", c.SQLDriver)
 	}
 
-	if c.AuthToken == "" {
-		return errors.New("cosmos grpc token is not set")
+	switch c.PriceSource {
+	case "":
+	case priceSourceFile:
+		if c.PriceFile == "" {
+			return errors.New("price file is not set")
+		}
+	case priceSourceHTTP:
+		if c.PriceAPIAddr == "" {
+			return errors.New("price api address is not set")
+		}
+		if c.PriceAPITimeout <= 0 {
+			return errors.New("price api timeout must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown price source %q", c.PriceSource)
 	}
 
-	if c.CosmosSearchAddr == "" {
-		return errors.New("cosmos search address is not set")
+	// Fiat columns are named after the currency and only written with one.
+	if c.PriceSource != "" && c.Currency == "" {
+		return errors.New("currency is not set")
 	}
 
-	if len(c.Accounts) == 0 {
-		return errors.New("at least one account must be provided")
+	for _, chain := range c.chains() {
+		if err := chain.validateAccounts(); err != nil {
+			return fmt.Errorf("chain %s: %w", chain.ChainID, err)
+		}
 	}
 
+	return c.validatePeriods()
+}
+
+// validateConnection checks what is needed to query the configured chains.
+func (c config) validateConnection() error {
+
+	// Public nodes behind the system CAs authenticate callers by token. Plaintext, private CA and mutual
+	// TLS connections are meant for local and in-cluster nodes that may not require one, as the client
+	// only sends a token when it is set.
+	switch c.TLSMode {
+	case "", client.TLSModeSystem:
+		if c.AuthToken == "" {
+			return errors.New("cosmos grpc token is not set")
+		}
+	case client.TLSModeInsecure:
+	case client.TLSModeCAFile:
+		if c.TLSCAFile == "" {
+			return errors.New("tls ca file is not set")
+		}
+	case client.TLSModeMTLS:
+		if c.TLSCertFile == "" || c.TLSKeyFile == "" {
+			return errors.New("tls cert file and key file must be set for mtls")
+		}
+	default:
+		return fmt.Errorf("unknown tls mode %q", c.TLSMode)
+	}
+
+	switch c.HeightSource {
+	case "", client.HeightSourceSearch, client.HeightSourceNode:
+	default:
+		return fmt.Errorf("unknown height source %q", c.HeightSource)
+	}
+
+	switch c.RewardsSource {
+	case "", client.RewardsSourceSearch, client.RewardsSourceNode:
+	default:
+		return fmt.Errorf("unknown rewards source %q", c.RewardsSource)
+	}
+
+	// The search service is only needed when something is read from it.
+	usesSearch := c.HeightSource != client.HeightSourceNode || c.RewardsSource != client.RewardsSourceNode
+	seen := map[string]bool{}
+	for i, chain := range c.chains() {
+		// Listed chains must say which chain they are, a default would report them under another's ID.
+		if chain.ChainID == "" {
+			return fmt.Errorf("chain %d of the chains list has no chain id", i+1)
+		}
+		if seen[chain.ChainID] {
+			return fmt.Errorf("chain %s is configured more than once", chain.ChainID)
+		}
+		seen[chain.ChainID] = true
+
+		if err := chain.validateConnection(usesSearch); err != nil {
+			return fmt.Errorf("chain %s: %w", chain.ChainID, err)
+		}
+
+		// The client refuses to send the token in plaintext to another host.
+		if c.TLSMode == client.TLSModeInsecure && c.AuthToken != "" {
+			for _, addr := range chain.grpcAddrs() {
+				if !client.IsLoopbackAddr(addr) {
+					return fmt.Errorf("chain %s: auth token can only be sent over plaintext to a loopback address, got %s", chain.ChainID, addr)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// validatePeriods checks the time range and how it is split into periods.
+func (c config) validatePeriods() error {
+
 	if c.StartTime.IsZero() {
 		return errors.New("start time is not set")
 	}
@@ -86,5 +306,82 @@ func (c config) validate() error {
 		return errors.New("start time must come before end time")
 	}
 
+	if _, err := c.location(); err != nil {
+		return err
+	}
+
+	switch c.Granularity {
+	case "", report.GranularityDaily, report.GranularityWeekly, report.GranularityMonthly,
+		report.GranularityQuarterly, report.GranularityYearly:
+	case report.GranularityCustom:
+		if len(c.PeriodBoundaries) < 2 {
+			return errors.New("at least two period boundaries must be provided for custom granularity")
+		}
+	default:
+		return fmt.Errorf("unknown granularity %q", c.Granularity)
+	}
+
 	return nil
 }
+
+// chain returns the only chain of the config.
+func (c config) chain() (chainConfig, error) {
+	chains := c.chains()
+	if len(chains) > 1 {
+		return chainConfig{}, errors.New("several chains are configured, select one with -chain-id")
+	}
+	return chains[0], nil
+}
+
+// location loads the IANA time zone periods are reported in.
+func (c config) location() (*time.Location, error) {
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", c.Timezone, err)
+	}
+	return loc, nil
+}
+
+// denomUnits are display units by base denom, given as base=display:exponent in the environment.
+type denomUnits map[string]client.DenomUnit
+
+func (d *denomUnits) Decode(value string) error {
+
+	units := denomUnits{}
+	for _, entry := range strings.Split(value, ",") {
+		if entry == "" {
+			continue
+		}
+
+		base, unit := splitPair(entry, "=")
+		display, exponent := splitPair(unit, ":")
+		exp, err := strconv.ParseUint(exponent, 10, 32)
+		if base == "" || display == "" || err != nil {
+			return fmt.Errorf("invalid denom unit %q, expected base=display:exponent", entry)
+		}
+
+		units[base] = client.DenomUnit{Display: display, Exponent: uint32(exp)}
+	}
+
+	*d = units
+	return nil
+}
+
+func splitPair(s, sep string) (string, string) {
+	parts := strings.SplitN(s, sep, 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// prices returns the configured price source, nil when there is none.
+func (c config) prices() (pricing.PriceSource, error) {
+	switch c.PriceSource {
+	case priceSourceFile:
+		return pricing.NewFileSource(c.PriceFile)
+	case priceSourceHTTP:
+		return pricing.NewHTTPSource(c.PriceAPIAddr, c.Currency, c.PriceAPITimeout), nil
+	}
+	return nil, nil
+}
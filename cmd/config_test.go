@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInitConfigFileDefaults(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	file := `{"cosmos_grpc_addr": "localhost:9090", "retry_max_attempts": 0, "currency": "", "workers": 4}`
+	if err := ioutil.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := initConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Explicit zero values are kept.
+	if cfg.RetryMaxAttempts != 0 {
+		t.Errorf("retry max attempts = %d, want the file's 0", cfg.RetryMaxAttempts)
+	}
+	if cfg.Currency != "" {
+		t.Errorf("currency = %q, want the file's empty value", cfg.Currency)
+	}
+	if cfg.Workers != 4 {
+		t.Errorf("workers = %d, want the file's 4", cfg.Workers)
+	}
+
+	// Fields the file leaves out get their defaults.
+	if cfg.RetryInitialBackoff != 500*time.Millisecond {
+		t.Errorf("retry initial backoff = %s, want the default 500ms", cfg.RetryInitialBackoff)
+	}
+	if cfg.PageSize != 100 {
+		t.Errorf("page size = %d, want the default 100", cfg.PageSize)
+	}
+	if cfg.Granularity != "monthly" {
+		t.Errorf("granularity = %q, want the default monthly", cfg.Granularity)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/figment-networks/cosmos-extract/report"
+)
+
+// Exit codes of the diff command.
+const (
+	diffExitAboveTolerance = 1
+	diffExitError          = 2
+)
+
+// runDiff compares two reports, or the runs recorded in two checkpoint stores, and returns the exit code.
+func runDiff(args []string) int {
+
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	tol := tolerances{byColumn: map[string]*big.Rat{}, byDenom: map[string]*big.Rat{}, byColumnDenom: map[report.DiffColumn]*big.Rat{}}
+	fs.Var(&tol, "tolerance", "Largest absolute delta of a value that is not reported as a difference, in the unit of the column and denom. "+
+		"Given as column@denom=value, column=value, @denom=value or value, which apply in that order, and repeated for several. Zero by default")
+	chainID := fs.String("chain-id", "", "Chain ID of the run to compare in checkpoint stores holding runs of several chains")
+	out := fs.String("out", "", "Path to write the differences to, stdout when empty")
+	format := fs.String("format", "", "Format of the differences: csv, json or ndjson. Picked from -out's extension when empty")
+	inFormat := fs.String("input-format", "", "Format of the reports compared, picked from their extension when empty")
+	checkpoints := fs.Bool("checkpoint", false, "Compare the runs recorded in two checkpoint stores instead of two reports")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: cosmos-extract diff [flags] OLD NEW")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return diffExitError
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return diffExitError
+	}
+
+	var tables [2]report.Table
+	for i, path := range fs.Args() {
+		var err error
+		if *checkpoints || isCheckpointPath(path) {
+			tables[i], err = report.LoadCheckpointTable(path, *chainID)
+		} else {
+			tables[i], err = report.LoadTable(path, *inFormat)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return diffExitError
+		}
+	}
+
+	diff, err := report.DiffTables(tables[0], tables[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return diffExitError
+	}
+
+	if err := writeOutput(*out, *format, diff.Table()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return diffExitError
+	}
+
+	code := 0
+	for _, total := range diff.Totals {
+		dc := report.DiffColumn{Column: total.Column, Denom: total.Key[len(total.Key)-1]}
+		max, limit := diff.MaxDeltas[dc], tol.of(dc)
+		if max.Cmp(limit) > 0 {
+			fmt.Fprintf(os.Stderr, "largest difference of %s in %s %s is above the tolerance of %s\n", dc.Column, dc.Denom, max.FloatString(6), limit.FloatString(6))
+			code = diffExitAboveTolerance
+		}
+	}
+
+	return code
+}
+
+// tolerances are the -tolerance flags by column, denom or both, with a default.
+type tolerances struct {
+	all           *big.Rat
+	byColumn      map[string]*big.Rat
+	byDenom       map[string]*big.Rat
+	byColumnDenom map[report.DiffColumn]*big.Rat
+}
+
+func (t *tolerances) String() string { return "" }
+
+func (t *tolerances) Set(v string) error {
+
+	target, value := "", v
+	if i := strings.LastIndex(v, "="); i >= 0 {
+		target, value = strings.TrimSpace(v[:i]), v[i+1:]
+		if target == "" {
+			return fmt.Errorf("no column or denom in %q", v)
+		}
+	}
+
+	// Denoms can't hold an @, so it separates them from the column.
+	column, denom := target, ""
+	if i := strings.Index(target, "@"); i >= 0 {
+		column, denom = target[:i], target[i+1:]
+		if denom == "" {
+			return fmt.Errorf("no denom in %q", v)
+		}
+	}
+
+	tol, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok || tol.Sign() < 0 {
+		return fmt.Errorf("invalid tolerance %q", value)
+	}
+
+	switch {
+	case column != "" && denom != "":
+		t.byColumnDenom[report.DiffColumn{Column: column, Denom: denom}] = tol
+	case column != "":
+		t.byColumn[column] = tol
+	case denom != "":
+		t.byDenom[denom] = tol
+	default:
+		t.all = tol
+	}
+	return nil
+}
+
+// of returns the tolerance of the column's values in the denom.
+func (t *tolerances) of(dc report.DiffColumn) *big.Rat {
+	if tol, ok := t.byColumnDenom[dc]; ok {
+		return tol
+	}
+	if tol, ok := t.byColumn[dc.Column]; ok {
+		return tol
+	}
+	if tol, ok := t.byDenom[dc.Denom]; ok {
+		return tol
+	}
+	if t.all != nil {
+		return t.all
+	}
+	return new(big.Rat)
+}
+
+// isCheckpointPath reports whether the path looks like a checkpoint store rather than a report.
+func isCheckpointPath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".db", ".bolt":
+		return true
+	}
+	return false
+}
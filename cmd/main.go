@@ -2,65 +2,232 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"os"
+	"strings"
 
 	"github.com/figment-networks/cosmos-extract/client"
+	"github.com/figment-networks/cosmos-extract/pricing"
 	"github.com/figment-networks/cosmos-extract/report"
 	"github.com/figment-networks/cosmos-worker/cmd/common/logger"
+	"golang.org/x/time/rate"
 )
 
-var configPath string
-
 func main() {
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	args := os.Args[1:]
 
-	flag.StringVar(&configPath, "config", "", "Path to config")
+	// Without a command the flags are the report's, as they were before there were commands.
+	name := "report"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
 
-	flag.Parse()
+	for _, cmd := range commands {
+		if cmd.name == name {
+			os.Exit(cmd.run(args))
+		}
+	}
 
-	cfg, err := initConfig(configPath)
-	if err != nil {
-		log.Fatalf("error initializing config [ERR: %v]", err.Error())
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
+
+func runReport(args []string) int {
+
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config")
+	resume := fs.Bool("resume", false, "Resume the run recorded in the checkpoint store")
+	overrides := &configOverrides{}
+	overrides.connection(fs)
+	overrides.periods(fs)
+	overrides.output(fs)
+
+	cfg, ok := loadConfig(fs, args, configPath, overrides)
+	if !ok {
+		return 2
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	logger.Init("console", "debug", []string{"stderr"})
 	defer logger.Sync()
 
 	if err := cfg.validate(); err != nil {
 		logger.Error(err)
-		return
+		return 1
 	}
 
-	clientConfig := client.Config{
-		GRPCAddr:               cfg.CosmosGRPCAddr,
-		SearchAddr:             cfg.CosmosSearchAddr,
-		AuthToken:              cfg.AuthToken,
-		GRPCMaxRecvSize:        cfg.GrpcMaxRecvSize,
-		GRPCMaxSendSize:        cfg.GrpcMaxSendSize,
-		RequestsPerSecond:      cfg.RequestsPerSecond,
-		TimeoutBlockCall:       cfg.TimeoutBlockCall,
-		TimeoutTransactionCall: cfg.TimeoutTransactionCall,
+	if *resume && cfg.CheckpointPath == "" {
+		logger.Error(errors.New("resuming requires a checkpoint path"))
+		return 1
+	}
+
+	var err error
+	var checkpoint *report.Checkpoint
+	if cfg.CheckpointPath != "" {
+		checkpoint, err = report.OpenCheckpoint(cfg.CheckpointPath)
+		if err != nil {
+			logger.Error(err)
+			return 1
+		}
+		defer checkpoint.Close()
 	}
-	cosmosClient, err := client.New(ctx, logger.GetLogger(), clientConfig)
+
+	var sink *report.SQLSink
+	if cfg.SQLDriver != "" {
+		sink, err = report.OpenSQLSink(ctx, cfg.SQLDriver, cfg.SQLDSN)
+		if err != nil {
+			logger.Error(err)
+			return 1
+		}
+		defer sink.Close()
+	}
+
+	// A single limiter keeps the whole run, across chains and archives, within the configured rate.
+	limiter := client.NewLimiter(cfg.RequestsPerSecond)
+
+	prices, err := cfg.prices()
 	if err != nil {
 		logger.Error(err)
-		return
+		return 1
+	}
+
+	chains := cfg.chains()
+	for _, chain := range chains {
+		run := reportRun{checkpoint: checkpoint, resume: *resume, sink: sink, prices: prices}
+		if err := run.runChainReport(ctx, cfg, limiter, chain, len(chains)); err != nil {
+			logger.Error(err)
+			return 1
+		}
 	}
-	defer cosmosClient.Close()
 
-	reportRunner := report.NewRunner(logger.GetLogger(), cosmosClient)
-	reportConfig := report.Config{
-		StartTime:  cfg.StartTime,
-		EndTime:    cfg.EndTime,
-		Accounts:   cfg.Accounts,
-		OutputPath: cfg.ReportOutput,
+	return 0
+}
+
+// reportRun holds what a report run shares across chains.
+type reportRun struct {
+	checkpoint *report.Checkpoint
+	resume     bool
+	sink       *report.SQLSink
+	prices     pricing.PriceSource
+}
+
+func (run reportRun) runChainReport(ctx context.Context, cfg *config, limiter *rate.Limiter, chain chainConfig, numChains int) error {
+
+	reportRunner, reportConfig, closeClients, err := newChainRunner(ctx, cfg, limiter, chain, numChains)
+	if err != nil {
+		return err
 	}
-	err = reportRunner.Run(ctx, &reportConfig)
+	defer closeClients()
+
+	reportConfig.Checkpoint = run.checkpoint
+	reportConfig.Resume = run.resume
+	reportConfig.Sink = run.sink
+	reportConfig.Prices = run.prices
+
+	return reportRunner.Run(ctx, reportConfig)
+}
+
+// newChainRunner returns a runner for the chain, its report config and a function closing its clients.
+func newChainRunner(ctx context.Context, cfg *config, limiter *rate.Limiter, chain chainConfig, numChains int) (report.Runner, *report.Config, func(), error) {
+
+	loc, err := cfg.location()
 	if err != nil {
-		logger.Error(err)
-		return
+		return nil, nil, nil, err
+	}
+
+	reportConfig := &report.Config{
+		Network:        chain.Network,
+		ChainID:        chain.ChainID,
+		StartTime:      cfg.StartTime,
+		EndTime:        cfg.EndTime,
+		Granularity:    cfg.Granularity,
+		Boundaries:     cfg.PeriodBoundaries,
+		Location:       loc,
+		PartialPeriods: cfg.PartialPeriods,
+		Workers:        cfg.Workers,
+		Accounts:       chain.Accounts,
+		OutputPath:     chain.outputPath(cfg.ReportOutput, numChains),
+		Format:         cfg.ReportFormat,
+		ValidatorOrder: cfg.ValidatorOrder,
+		DisplayUnits:   cfg.DisplayUnits,
+		DenomUnits:     cfg.DenomUnits,
+		Currency:       cfg.Currency,
+		HeightSource:   cfg.HeightSource,
+		RewardsSource:  cfg.RewardsSource,
 	}
+
+	var clients []client.Client
+	closeClients := func() {
+		for _, c := range clients {
+			c.Close()
+		}
+	}
+
+	for _, seg := range chain.History {
+		segClient, err := newClient(ctx, cfg, limiter, seg.CosmosGRPCAddr, seg.CosmosSearchAddr)
+		if err != nil {
+			closeClients()
+			return nil, nil, nil, err
+		}
+		clients = append(clients, segClient)
+
+		reportConfig.Segments = append(reportConfig.Segments, report.Segment{
+			ChainID:     seg.ChainID,
+			StartTime:   seg.StartTime,
+			EndTime:     seg.EndTime,
+			StartHeight: seg.StartHeight,
+			EndHeight:   seg.EndHeight,
+			Client:      segClient,
+		})
+	}
+
+	// With a history every segment has its own client, but the runner still needs a default one.
+	var cosmosClient client.Client
+	if len(reportConfig.Segments) > 0 {
+		cosmosClient = reportConfig.Segments[len(reportConfig.Segments)-1].Client
+	} else {
+		cosmosClient, err = newClient(ctx, cfg, limiter, chain.CosmosGRPCAddr, chain.CosmosSearchAddr)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		clients = append(clients, cosmosClient)
+	}
+
+	return report.NewRunner(logger.GetLogger(), cosmosClient), reportConfig, closeClients, nil
+}
+
+func newClient(ctx context.Context, cfg *config, limiter *rate.Limiter, grpcAddr, searchAddr string) (client.Client, error) {
+
+	clientConfig := client.Config{
+		GRPCAddr:          grpcAddr,
+		SearchAddr:        searchAddr,
+		AuthToken:         cfg.AuthToken,
+		TLSMode:           cfg.TLSMode,
+		TLSCAFile:         cfg.TLSCAFile,
+		TLSCertFile:       cfg.TLSCertFile,
+		TLSKeyFile:        cfg.TLSKeyFile,
+		HeightSource:      cfg.HeightSource,
+		RewardsSource:     cfg.RewardsSource,
+		GRPCMaxRecvSize:   cfg.GrpcMaxRecvSize,
+		GRPCMaxSendSize:   cfg.GrpcMaxSendSize,
+		RequestsPerSecond: cfg.RequestsPerSecond,
+		PageSize:          cfg.PageSize,
+		Limiter:           limiter,
+		Retry: client.RetryConfig{
+			MaxAttempts:    cfg.RetryMaxAttempts,
+			InitialBackoff: cfg.RetryInitialBackoff,
+			MaxBackoff:     cfg.RetryMaxBackoff,
+			Budget:         cfg.RetryBudget,
+		},
+		TimeoutBlockCall:       cfg.TimeoutBlockCall,
+		TimeoutTransactionCall: cfg.TimeoutTransactionCall,
+	}
+
+	return client.New(ctx, logger.GetLogger(), clientConfig)
 }
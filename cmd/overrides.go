@@ -0,0 +1,316 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// configOverrides are flags overriding config values, applied once the config is loaded.
+type configOverrides struct {
+	pending []func(cfg *config) error
+	// checks run once every override is applied, for overrides that depend on others.
+	checks []func(cfg *config) error
+}
+
+// overrideFlag records the flag's value to be set on the config later.
+type overrideFlag struct {
+	o      *configOverrides
+	name   string
+	isBool bool
+	set    func(cfg *config, value string) error
+}
+
+func (f overrideFlag) String() string { return "" }
+
+func (f overrideFlag) IsBoolFlag() bool { return f.isBool }
+
+func (f overrideFlag) Set(value string) error {
+	f.o.pending = append(f.o.pending, func(cfg *config) error {
+		if err := f.set(cfg, value); err != nil {
+			return fmt.Errorf("invalid -%s: %w", f.name, err)
+		}
+		return nil
+	})
+	return nil
+}
+
+func (o *configOverrides) add(fs *flag.FlagSet, name, usage string, set func(cfg *config, value string) error) {
+	fs.Var(overrideFlag{o: o, name: name, set: set}, name, usage)
+}
+
+func (o *configOverrides) addBool(fs *flag.FlagSet, name, usage string, set func(cfg *config, value bool)) {
+	fs.Var(overrideFlag{o: o, name: name, isBool: true, set: func(cfg *config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		set(cfg, b)
+		return nil
+	}}, name, usage)
+}
+
+func (o *configOverrides) apply(cfg *config) error {
+	for _, set := range o.pending {
+		if err := set(cfg); err != nil {
+			return err
+		}
+	}
+	for _, check := range o.checks {
+		if err := check(cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// noHistory rejects the flag when a selected chain has a history.
+func (o *configOverrides) noHistory(name string) {
+	o.checks = append(o.checks, func(cfg *config) error {
+		for _, chain := range cfg.Chains {
+			if len(chain.History) > 0 {
+				return fmt.Errorf("invalid -%s: chain %s has a history, set the addresses of its segments in the config instead", name, chain.ChainID)
+			}
+		}
+		return nil
+	})
+}
+
+// connection adds the flags selecting the chain and how to reach it.
+func (o *configOverrides) connection(fs *flag.FlagSet) {
+
+	o.add(fs, "chain-id", "Chain ID, selecting that chain when several are configured", func(cfg *config, v string) error {
+		cfg.ChainID = v
+		if len(cfg.Chains) == 0 {
+			return nil
+		}
+		for _, chain := range cfg.Chains {
+			if chain.ChainID == v {
+				cfg.Chains = []chainConfig{chain}
+				return nil
+			}
+		}
+		return fmt.Errorf("chain %s is not configured", v)
+	})
+	o.add(fs, "network", "Network name", func(cfg *config, v string) error {
+		cfg.Network = v
+		for i := range cfg.Chains {
+			cfg.Chains[i].Network = v
+		}
+		return nil
+	})
+	o.add(fs, "grpc-addr", "Cosmos gRPC address", func(cfg *config, v string) error {
+		cfg.CosmosGRPCAddr = v
+		for i := range cfg.Chains {
+			cfg.Chains[i].CosmosGRPCAddr = v
+		}
+		o.noHistory("grpc-addr")
+		return nil
+	})
+	o.add(fs, "search-addr", "Cosmos search service address", func(cfg *config, v string) error {
+		cfg.CosmosSearchAddr = v
+		for i := range cfg.Chains {
+			cfg.Chains[i].CosmosSearchAddr = v
+		}
+		o.noHistory("search-addr")
+		return nil
+	})
+	o.add(fs, "auth-token", "Auth token of the gRPC and search services", func(cfg *config, v string) error {
+		cfg.AuthToken = v
+		return nil
+	})
+	o.add(fs, "tls-mode", "TLS mode of the gRPC and search connections: insecure, system, ca-file or mtls", func(cfg *config, v string) error {
+		cfg.TLSMode = v
+		return nil
+	})
+	o.add(fs, "tls-ca-file", "Path of the CA certificates trusted in ca-file and mtls modes", func(cfg *config, v string) error {
+		cfg.TLSCAFile = v
+		return nil
+	})
+	o.add(fs, "tls-cert-file", "Path of the client certificate of mtls mode", func(cfg *config, v string) error {
+		cfg.TLSCertFile = v
+		return nil
+	})
+	o.add(fs, "tls-key-file", "Path of the client key of mtls mode", func(cfg *config, v string) error {
+		cfg.TLSKeyFile = v
+		return nil
+	})
+	o.add(fs, "height-source", "Where period heights are looked up: search or node", func(cfg *config, v string) error {
+		cfg.HeightSource = v
+		return nil
+	})
+	o.add(fs, "rewards-source", "Where rewards are read from: search or node", func(cfg *config, v string) error {
+		cfg.RewardsSource = v
+		return nil
+	})
+	o.add(fs, "requests-per-second", "Largest number of requests sent per second", func(cfg *config, v string) (err error) {
+		cfg.RequestsPerSecond, err = strconv.Atoi(v)
+		return err
+	})
+	o.add(fs, "page-size", "Number of items requested per page", func(cfg *config, v string) (err error) {
+		cfg.PageSize, err = strconv.ParseUint(v, 10, 64)
+		return err
+	})
+	o.add(fs, "retry-max-attempts", "Largest number of attempts of a request, the first included", func(cfg *config, v string) (err error) {
+		cfg.RetryMaxAttempts, err = strconv.Atoi(v)
+		return err
+	})
+	o.add(fs, "retry-initial-backoff", "Wait before the first retry of a request, such as 500ms, doubled for every later one", func(cfg *config, v string) (err error) {
+		cfg.RetryInitialBackoff, err = time.ParseDuration(v)
+		return err
+	})
+	o.add(fs, "retry-max-backoff", "Longest wait between retries of a request", func(cfg *config, v string) (err error) {
+		cfg.RetryMaxBackoff, err = time.ParseDuration(v)
+		return err
+	})
+	o.add(fs, "retry-budget", "Longest time spent on a request, retries and the waits between them included", func(cfg *config, v string) (err error) {
+		cfg.RetryBudget, err = time.ParseDuration(v)
+		return err
+	})
+}
+
+// periods adds the flags of the time range and how it is split into periods.
+func (o *configOverrides) periods(fs *flag.FlagSet) {
+
+	o.add(fs, "start", "Start time, as RFC 3339 or a date", func(cfg *config, v string) (err error) {
+		cfg.StartTime, err = parseFlagTime(v)
+		return err
+	})
+	o.add(fs, "end", "End time, as RFC 3339 or a date", func(cfg *config, v string) (err error) {
+		cfg.EndTime, err = parseFlagTime(v)
+		return err
+	})
+	o.add(fs, "granularity", "Length of the periods: daily, weekly, monthly, quarterly, yearly or custom", func(cfg *config, v string) error {
+		cfg.Granularity = v
+		return nil
+	})
+	o.add(fs, "period-boundaries", "Comma separated times the periods of custom granularity start and end at, as RFC 3339 or dates", func(cfg *config, v string) error {
+		boundaries := []time.Time{}
+		for _, item := range splitList(v) {
+			t, err := parseFlagTime(item)
+			if err != nil {
+				return err
+			}
+			boundaries = append(boundaries, t)
+		}
+		cfg.PeriodBoundaries = boundaries
+		return nil
+	})
+	o.add(fs, "timezone", "IANA time zone period boundaries fall on", func(cfg *config, v string) error {
+		cfg.Timezone = v
+		return nil
+	})
+	o.addBool(fs, "partial-periods", "Clip the first and last periods to the start and end times", func(cfg *config, v bool) {
+		cfg.PartialPeriods = v
+	})
+}
+
+// output adds the flags of what the report covers and where it is written.
+func (o *configOverrides) output(fs *flag.FlagSet) {
+
+	o.add(fs, "accounts", "Comma separated accounts to report on", func(cfg *config, v string) error {
+		accounts := splitList(v)
+		cfg.Accounts = accounts
+		for i := range cfg.Chains {
+			cfg.Chains[i].Accounts = accounts
+		}
+		return nil
+	})
+	o.add(fs, "output", "Path of the report", func(cfg *config, v string) error {
+		cfg.ReportOutput = v
+		return nil
+	})
+	o.add(fs, "format", "Format of the report: csv, json, ndjson, parquet or xlsx", func(cfg *config, v string) error {
+		cfg.ReportFormat = v
+		return nil
+	})
+	o.add(fs, "validator-order", "Order of the validators: address or moniker", func(cfg *config, v string) error {
+		cfg.ValidatorOrder = v
+		return nil
+	})
+	o.add(fs, "workers", "Number of (period, account) pairs fetched concurrently", func(cfg *config, v string) (err error) {
+		cfg.Workers, err = strconv.Atoi(v)
+		return err
+	})
+	o.add(fs, "checkpoint", "Path of the checkpoint store", func(cfg *config, v string) error {
+		cfg.CheckpointPath = v
+		return nil
+	})
+	o.addBool(fs, "display-units", "Also write amounts in display units", func(cfg *config, v bool) {
+		cfg.DisplayUnits = v
+	})
+	o.add(fs, "price-source", "Source of fiat prices: file or http", func(cfg *config, v string) error {
+		cfg.PriceSource = v
+		return nil
+	})
+	o.add(fs, "price-file", "Path of the price file", func(cfg *config, v string) error {
+		cfg.PriceFile = v
+		return nil
+	})
+	o.add(fs, "price-api-addr", "Address of the http price source", func(cfg *config, v string) error {
+		cfg.PriceAPIAddr = v
+		return nil
+	})
+	o.add(fs, "price-api-timeout", "Timeout of requests to the http price source, such as 30s", func(cfg *config, v string) (err error) {
+		cfg.PriceAPITimeout, err = time.ParseDuration(v)
+		return err
+	})
+	o.add(fs, "currency", "Fiat currency rewards are valued in", func(cfg *config, v string) error {
+		cfg.Currency = v
+		return nil
+	})
+	o.add(fs, "sql-driver", "SQL sink driver: sqlite3 (needs a cgo build) or postgres", func(cfg *config, v string) error {
+		cfg.SQLDriver = v
+		return nil
+	})
+	o.add(fs, "sql-dsn", "SQL sink data source name", func(cfg *config, v string) error {
+		cfg.SQLDSN = v
+		return nil
+	})
+}
+
+// loadConfig parses the flags, loads the config and applies the overrides, reporting problems on stderr.
+func loadConfig(fs *flag.FlagSet, args []string, configPath *string, overrides *configOverrides) (*config, bool) {
+
+	if err := fs.Parse(args); err != nil {
+		return nil, false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments %v\n", fs.Args())
+		return nil, false
+	}
+
+	cfg, err := initConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error initializing config [ERR: %v]\n", err)
+		return nil, false
+	}
+
+	if err := overrides.apply(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, false
+	}
+
+	return cfg, true
+}
+
+// parseFlagTime reads an RFC 3339 time or a date, which is taken as midnight UTC.
+func parseFlagTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,113 @@
+package pricing
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileSource serves prices from a JSON or CSV file of date, denom and price entries.
+type FileSource struct {
+	prices map[priceKey]*big.Rat
+	// digest is the SHA-256 of the file's content.
+	digest string
+}
+
+type priceKey struct {
+	denom string
+	day   string
+}
+
+type filePrice struct {
+	Date  string `json:"date"`
+	Denom string `json:"denom"`
+	Price string `json:"price"`
+}
+
+func NewFileSource(path string) (*FileSource, error) {
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open price file: %w", err)
+	}
+
+	var entries []filePrice
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.NewDecoder(bytes.NewReader(content)).Decode(&entries)
+	} else {
+		entries, err = readCSVPrices(bytes.NewReader(content))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read price file %s: %w", path, err)
+	}
+
+	sum := sha256.Sum256(content)
+	fs := &FileSource{prices: map[priceKey]*big.Rat{}, digest: hex.EncodeToString(sum[:])}
+	for _, e := range entries {
+		if _, err := time.Parse("2006-01-02", e.Date); err != nil {
+			return nil, fmt.Errorf("invalid date %q in price file %s", e.Date, path)
+		}
+		price, err := parsePrice(e.Price)
+		if err != nil {
+			return nil, fmt.Errorf("%s on %s in price file %s: %w", e.Denom, e.Date, path, err)
+		}
+		fs.prices[priceKey{denom: e.Denom, day: e.Date}] = price
+	}
+
+	return fs, nil
+}
+
+func readCSVPrices(r io.Reader) ([]filePrice, error) {
+
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, h := range records[0] {
+		columns[strings.TrimSpace(strings.ToLower(h))] = i
+	}
+	for _, h := range []string{"date", "denom", "price"} {
+		if _, ok := columns[h]; !ok {
+			return nil, fmt.Errorf("missing %s column", h)
+		}
+	}
+
+	entries := make([]filePrice, 0, len(records)-1)
+	for _, rec := range records[1:] {
+		entries = append(entries, filePrice{
+			Date:  rec[columns["date"]],
+			Denom: rec[columns["denom"]],
+			Price: rec[columns["price"]],
+		})
+	}
+
+	return entries, nil
+}
+
+// ID changes with the content of the file.
+func (fs *FileSource) ID() string {
+	return "file:" + fs.digest
+}
+
+func (fs *FileSource) Price(ctx context.Context, denom string, at time.Time) (*big.Rat, error) {
+	price, ok := fs.prices[priceKey{denom: denom, day: day(at)}]
+	if !ok {
+		return nil, fmt.Errorf("%w for %s on %s", ErrNoPrice, denom, day(at))
+	}
+	return price, nil
+}
@@ -0,0 +1,127 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writePriceFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileSource(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name:    "csv",
+			file:    "prices.csv",
+			content: "date,denom,price\n2021-03-01,uatom,17.25\n2021-03-02,uatom,18\n",
+		},
+		{
+			name:    "csv with reordered columns",
+			file:    "prices.txt",
+			content: "Price, Denom ,DATE\n17.25,uatom,2021-03-01\n18,uatom,2021-03-02\n",
+		},
+		{
+			name:    "json",
+			file:    "prices.json",
+			content: `[{"date":"2021-03-01","denom":"uatom","price":"17.25"},{"date":"2021-03-02","denom":"uatom","price":"18"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, err := NewFileSource(writePriceFile(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Prices are keyed by UTC day, whatever the time of day or zone asked for.
+			at := time.Date(2021, 3, 1, 23, 30, 0, 0, time.UTC)
+			price, err := fs.Price(context.Background(), "uatom", at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if price.RatString() != "69/4" {
+				t.Errorf("price on 2021-03-01 = %s, want 17.25", price.FloatString(2))
+			}
+
+			at = time.Date(2021, 3, 2, 1, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+			price, err = fs.Price(context.Background(), "uatom", at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if price.RatString() != "69/4" {
+				t.Errorf("price at %s = %s, want the 2021-03-01 price", at, price.FloatString(2))
+			}
+
+			_, err = fs.Price(context.Background(), "uatom", time.Date(2021, 3, 3, 0, 0, 0, 0, time.UTC))
+			if !errors.Is(err, ErrNoPrice) {
+				t.Errorf("missing day: got %v, want ErrNoPrice", err)
+			}
+
+			_, err = fs.Price(context.Background(), "uosmo", at)
+			if !errors.Is(err, ErrNoPrice) {
+				t.Errorf("missing denom: got %v, want ErrNoPrice", err)
+			}
+		})
+	}
+}
+
+func TestFileSourceInvalid(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"bad csv date", "prices.csv", "date,denom,price\n03/01/2021,uatom,17.25\n"},
+		{"bad json date", "prices.json", `[{"date":"2021-3-1","denom":"uatom","price":"17.25"}]`},
+		{"negative csv price", "prices.csv", "date,denom,price\n2021-03-01,uatom,-1\n"},
+		{"negative json price", "prices.json", `[{"date":"2021-03-01","denom":"uatom","price":"-0.5"}]`},
+		{"non numeric price", "prices.csv", "date,denom,price\n2021-03-01,uatom,abc\n"},
+		{"missing column", "prices.csv", "date,price\n2021-03-01,17.25\n"},
+		{"malformed json", "prices.json", `{"date":"2021-03-01"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewFileSource(writePriceFile(t, tt.file, tt.content)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestFileSourceID(t *testing.T) {
+
+	id := func(content string) string {
+		t.Helper()
+		fs, err := NewFileSource(writePriceFile(t, "prices.csv", content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fs.ID()
+	}
+
+	a := id("date,denom,price\n2021-03-01,uatom,17.25\n")
+	if a != id("date,denom,price\n2021-03-01,uatom,17.25\n") {
+		t.Error("files with the same prices have different IDs")
+	}
+	if a == id("date,denom,price\n2021-03-01,uatom,17.5\n") {
+		t.Error("files with different prices have the same ID")
+	}
+}
@@ -0,0 +1,111 @@
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPSource gets prices from GET <addr>/prices?denom=&date=&currency=, caching them.
+type HTTPSource struct {
+	addr     string
+	currency string
+	client   http.Client
+
+	mu    sync.Mutex
+	cache map[priceKey]*big.Rat
+}
+
+type priceResp struct {
+	Price string `json:"price"`
+}
+
+func NewHTTPSource(addr, currency string, timeout time.Duration) *HTTPSource {
+	return &HTTPSource{
+		addr:     addr,
+		currency: currency,
+		client:   http.Client{Timeout: timeout},
+		cache:    map[priceKey]*big.Rat{},
+	}
+}
+
+func (hs *HTTPSource) ID() string {
+	return "http:" + hs.addr + "/" + hs.currency
+}
+
+func (hs *HTTPSource) Price(ctx context.Context, denom string, at time.Time) (*big.Rat, error) {
+
+	key := priceKey{denom: denom, day: day(at)}
+
+	hs.mu.Lock()
+	price, ok := hs.cache[key]
+	hs.mu.Unlock()
+	if ok {
+		return price, nil
+	}
+
+	price, err := hs.fetch(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	hs.mu.Lock()
+	hs.cache[key] = price
+	hs.mu.Unlock()
+
+	return price, nil
+}
+
+func (hs *HTTPSource) fetch(ctx context.Context, key priceKey) (*big.Rat, error) {
+
+	url := hs.addr
+	if !strings.HasSuffix(url, "/") {
+		url += "/"
+	}
+
+	params := neturl.Values{}
+	params.Add("denom", key.denom)
+	params.Add("date", key.day)
+	params.Add("currency", hs.currency)
+	url += "prices?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := hs.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not get price of %s on %s: %w", key.denom, key.day, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w for %s on %s", ErrNoPrice, key.denom, key.day)
+	default:
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("price api responded with status %d: %s", resp.StatusCode, body)
+	}
+
+	var pr priceResp
+	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+		return nil, fmt.Errorf("could not decode price of %s on %s: %w", key.denom, key.day, err)
+	}
+
+	price, err := parsePrice(pr.Price)
+	if err != nil {
+		return nil, fmt.Errorf("%s on %s: %w", key.denom, key.day, err)
+	}
+
+	return price, nil
+}
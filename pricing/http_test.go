@@ -0,0 +1,107 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPSource(t *testing.T) {
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		q := r.URL.Query()
+		if r.URL.Path != "/prices" || q.Get("currency") != "EUR" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		switch q.Get("denom") + "/" + q.Get("date") {
+		case "uatom/2021-03-01":
+			w.Write([]byte(`{"price":"17.25"}`))
+		case "uatom/2021-03-02":
+			http.NotFound(w, r)
+		case "uosmo/2021-03-01":
+			http.Error(w, "upstream unavailable", http.StatusServiceUnavailable)
+		case "ujuno/2021-03-01":
+			w.Write([]byte(`{"price":"-3"}`))
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	hs := NewHTTPSource(srv.URL, "EUR", 5*time.Second)
+	day1 := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	day2 := time.Date(2021, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	t.Run("ok", func(t *testing.T) {
+		price, err := hs.Price(ctx, "uatom", day1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if price.RatString() != "69/4" {
+			t.Errorf("price = %s, want 17.25", price.FloatString(2))
+		}
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		before := atomic.LoadInt32(&requests)
+		if _, err := hs.Price(ctx, "uatom", day1.Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if after := atomic.LoadInt32(&requests); after != before {
+			t.Errorf("a cached price was requested again, %d requests instead of %d", after, before)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if _, err := hs.Price(ctx, "uatom", day2); !errors.Is(err, ErrNoPrice) {
+			t.Errorf("got %v, want ErrNoPrice", err)
+		}
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		_, err := hs.Price(ctx, "uosmo", day1)
+		if err == nil || errors.Is(err, ErrNoPrice) {
+			t.Errorf("got %v, want a status error", err)
+		}
+	})
+
+	t.Run("errors not cached", func(t *testing.T) {
+		before := atomic.LoadInt32(&requests)
+		hs.Price(ctx, "uosmo", day1)
+		if after := atomic.LoadInt32(&requests); after != before+1 {
+			t.Errorf("a failed price was not requested again")
+		}
+	})
+
+	t.Run("invalid price", func(t *testing.T) {
+		if _, err := hs.Price(ctx, "ujuno", day1); err == nil {
+			t.Error("expected an error for a negative price")
+		}
+	})
+}
+
+func TestHTTPSourceTimeout(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	hs := NewHTTPSource(srv.URL, "USD", 50*time.Millisecond)
+	if _, err := hs.Price(context.Background(), "uatom", time.Now()); err == nil {
+		t.Error("expected the request to time out")
+	}
+}
@@ -0,0 +1,33 @@
+// Package pricing looks up the fiat prices used to value rewards.
+package pricing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// ErrNoPrice is returned when a source has no price for the denom on the requested day.
+var ErrNoPrice = errors.New("no price found")
+
+// PriceSource returns the daily fiat price of one display unit of a base denom.
+type PriceSource interface {
+	Price(ctx context.Context, denom string, at time.Time) (*big.Rat, error)
+	// ID identifies the source and the prices it serves, so values computed with other prices are never
+	// taken for its own.
+	ID() string
+}
+
+// day is the UTC day the time falls on, which prices are keyed by.
+func day(at time.Time) string {
+	return at.UTC().Format("2006-01-02")
+}
+
+func parsePrice(value string) (*big.Rat, error) {
+	price, ok := new(big.Rat).SetString(value)
+	if !ok || price.Sign() < 0 {
+		return nil, errors.New("invalid price " + value)
+	}
+	return price, nil
+}
@@ -0,0 +1,400 @@
+package report
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/figment-networks/cosmos-extract/client"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	periodsBucket = []byte("periods")
+	resultsBucket = []byte("results")
+	// runInfoKey holds the checkpointRun of a run's bucket, as its name is a hash.
+	runInfoKey = []byte("run")
+)
+
+// checkpointVersion is the encoding version of checkpointed results, part of the run key.
+const checkpointVersion = 3
+
+// errCheckpointVersion is returned when decoding a result written with another checkpointVersion.
+var errCheckpointVersion = errors.New("checkpointed result was written by another version")
+
+// checkpointTimeout is how long opening a store waits for another process holding it to let go.
+const checkpointTimeout = time.Second
+
+// Checkpoint persists the resolved period heights and every completed (period, account) result of a
+// run, so a run that failed part way through can be resumed without repeating finished work.
+type Checkpoint struct {
+	db *bolt.DB
+}
+
+func OpenCheckpoint(path string) (*Checkpoint, error) {
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: checkpointTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("could not open checkpoint store %s: %w", path, err)
+	}
+
+	return &Checkpoint{db: db}, nil
+}
+
+func (c *Checkpoint) Close() error {
+	return c.db.Close()
+}
+
+// runKey identifies the checkpointed data of a run by everything that determines its periods and how
+// their results are computed. Results for a different range, granularity, source, prices, denom units or
+// chain history are never mixed up with this run's.
+func runKey(cfg *Config) []byte {
+
+	boundaries := make([]string, len(cfg.Boundaries))
+	for i, b := range cfg.Boundaries {
+		boundaries[i] = b.UTC().Format(time.RFC3339Nano)
+	}
+
+	segments := make([]string, len(cfg.Segments))
+	for i, s := range cfg.Segments {
+		segments[i] = fmt.Sprintf("%s/%s/%s/%d/%d", s.ChainID,
+			s.StartTime.UTC().Format(time.RFC3339Nano), s.EndTime.UTC().Format(time.RFC3339Nano), s.StartHeight, s.EndHeight)
+	}
+
+	key, _ := json.Marshal(struct {
+		Version        int
+		Network        string
+		ChainID        string
+		StartTime      string
+		EndTime        string
+		Granularity    string
+		Boundaries     []string
+		Location       string
+		PartialPeriods bool
+		Currency       string
+		PriceSource    string
+		DenomUnits     map[string]client.DenomUnit
+		HeightSource   string
+		RewardsSource  string
+		Segments       []string
+	}{
+		Version:        checkpointVersion,
+		Network:        cfg.Network,
+		ChainID:        cfg.ChainID,
+		StartTime:      cfg.StartTime.UTC().Format(time.RFC3339Nano),
+		EndTime:        cfg.EndTime.UTC().Format(time.RFC3339Nano),
+		Granularity:    cfg.Granularity,
+		Boundaries:     boundaries,
+		Location:       location(cfg).String(),
+		PartialPeriods: cfg.PartialPeriods,
+		Currency:       currency(cfg),
+		PriceSource:    priceSourceID(cfg),
+		DenomUnits:     cfg.DenomUnits,
+		HeightSource:   sourceOrDefault(cfg.HeightSource, client.HeightSourceSearch),
+		RewardsSource:  sourceOrDefault(cfg.RewardsSource, client.RewardsSourceSearch),
+		Segments:       segments,
+	})
+
+	sum := sha256.Sum256(key)
+	return []byte(hex.EncodeToString(sum[:]))
+}
+
+func sourceOrDefault(source, def string) string {
+	if source == "" {
+		return def
+	}
+	return source
+}
+
+// priceSourceID identifies the prices fiat values are checkpointed with, empty without prices.
+func priceSourceID(cfg *Config) string {
+	if cfg.Prices == nil {
+		return ""
+	}
+	return cfg.Prices.ID()
+}
+
+// currency is the currency of the fiat values checkpointed for the run, empty without prices.
+func currency(cfg *Config) string {
+	if cfg.Prices == nil {
+		return ""
+	}
+	return cfg.Currency
+}
+
+// reset drops everything checkpointed for the run.
+func (c *Checkpoint) reset(cfg *Config) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket(runKey(cfg))
+		if err == bolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+type checkpointPeriod struct {
+	EndHeight uint64 `json:"end_height"`
+	ChainID   string `json:"chain_id"`
+}
+
+func periodKey(b periodBound) []byte {
+	return []byte(b.startTime.UTC().Format(time.RFC3339Nano) + "/" + b.nextStartTime.UTC().Format(time.RFC3339Nano))
+}
+
+func (c *Checkpoint) loadPeriod(cfg *Config, b periodBound) (cp checkpointPeriod, ok bool, err error) {
+
+	err = c.db.View(func(tx *bolt.Tx) error {
+		v := bucketValue(tx, runKey(cfg), periodsBucket, periodKey(b))
+		if v == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(v, &cp)
+	})
+
+	return
+}
+
+func (c *Checkpoint) savePeriod(cfg *Config, b periodBound, cp checkpointPeriod) error {
+
+	v, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	return c.put(cfg, periodsBucket, periodKey(b), v)
+}
+
+// checkpointResult mirrors durationResult with exported fields so it can be encoded.
+type checkpointResult struct {
+	Version          int                     `json:"version"`
+	Duration         time.Time               `json:"duration"`
+	Label            string                  `json:"label"`
+	Validators       []string                `json:"validators"`
+	Delegations      map[string]client.Coins `json:"delegations"`
+	Unbonding        map[string]client.Coins `json:"unbonding"`
+	RedelegationsIn  map[string]client.Coins `json:"redelegations_in"`
+	RedelegationsOut map[string]client.Coins `json:"redelegations_out"`
+	Rewards          map[string]client.Coins `json:"rewards"`
+	Fees             map[string]client.Coins `json:"fees"`
+	PendingRewards   map[string]client.Coins `json:"pending_rewards"`
+	RewardsFiat      fiatAmounts             `json:"rewards_fiat,omitempty"`
+	FeesFiat         fiatAmounts             `json:"fees_fiat,omitempty"`
+	Liquid           client.Coins            `json:"liquid"`
+	Delegated        client.Coins            `json:"delegated"`
+	UnbondingByDenom client.Coins            `json:"unbonding_by_denom"`
+	UnclaimedRewards client.Coins            `json:"unclaimed_rewards"`
+}
+
+func resultKey(acc string, periodIndex int) []byte {
+	return []byte(acc + "/" + strconv.Itoa(periodIndex))
+}
+
+func (c *Checkpoint) loadResult(cfg *Config, acc string, periodIndex int) (dr durationResult, ok bool, err error) {
+
+	err = c.db.View(func(tx *bolt.Tx) error {
+		v := bucketValue(tx, runKey(cfg), resultsBucket, resultKey(acc, periodIndex))
+		if v == nil {
+			return nil
+		}
+
+		// Results in another encoding are computed again.
+		dr, err = decodeCheckpointResult(v)
+		if errors.Is(err, errCheckpointVersion) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ok = true
+
+		return nil
+	})
+
+	return
+}
+
+func decodeCheckpointResult(v []byte) (dr durationResult, err error) {
+
+	var cr checkpointResult
+	if err := json.Unmarshal(v, &cr); err != nil {
+		return dr, err
+	}
+	if cr.Version != checkpointVersion {
+		return dr, fmt.Errorf("%w %d", errCheckpointVersion, cr.Version)
+	}
+
+	dr = initDurationResult(cr.Duration, cr.Label)
+	for _, v := range cr.Validators {
+		dr.validators[v] = true
+	}
+	dr.delegations = nonNilValidatorAmounts(cr.Delegations)
+	dr.unbonding = nonNilValidatorAmounts(cr.Unbonding)
+	dr.redelegationsIn = nonNilValidatorAmounts(cr.RedelegationsIn)
+	dr.redelegationsOut = nonNilValidatorAmounts(cr.RedelegationsOut)
+	dr.rewards = nonNilValidatorAmounts(cr.Rewards)
+	dr.fees = nonNilValidatorAmounts(cr.Fees)
+	dr.pendingRewards = nonNilValidatorAmounts(cr.PendingRewards)
+	if cr.RewardsFiat != nil {
+		dr.rewardsFiat = cr.RewardsFiat
+	}
+	if cr.FeesFiat != nil {
+		dr.feesFiat = cr.FeesFiat
+	}
+	dr.holdings = holdings{
+		liquid:           nonNilAmounts(cr.Liquid),
+		delegated:        nonNilAmounts(cr.Delegated),
+		unbonding:        nonNilAmounts(cr.UnbondingByDenom),
+		unclaimedRewards: nonNilAmounts(cr.UnclaimedRewards),
+	}
+
+	return dr, nil
+}
+
+func (c *Checkpoint) saveResult(cfg *Config, acc string, periodIndex int, dr durationResult) error {
+
+	cr := checkpointResult{
+		Version:          checkpointVersion,
+		Duration:         dr.duration,
+		Label:            dr.label,
+		Delegations:      dr.delegations,
+		Unbonding:        dr.unbonding,
+		RedelegationsIn:  dr.redelegationsIn,
+		RedelegationsOut: dr.redelegationsOut,
+		Rewards:          dr.rewards,
+		Fees:             dr.fees,
+		PendingRewards:   dr.pendingRewards,
+		RewardsFiat:      dr.rewardsFiat,
+		FeesFiat:         dr.feesFiat,
+		Liquid:           dr.holdings.liquid,
+		Delegated:        dr.holdings.delegated,
+		UnbondingByDenom: dr.holdings.unbonding,
+		UnclaimedRewards: dr.holdings.unclaimedRewards,
+	}
+	for v := range dr.validators {
+		cr.Validators = append(cr.Validators, v)
+	}
+
+	v, err := json.Marshal(cr)
+	if err != nil {
+		return err
+	}
+
+	return c.put(cfg, resultsBucket, resultKey(acc, periodIndex), v)
+}
+
+// checkpointRun describes a run of the store, and its report's options once it completes.
+type checkpointRun struct {
+	ChainID   string    `json:"chain_id"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Complete  bool      `json:"complete"`
+	// Currency of the fiat value columns, empty without prices.
+	Currency string `json:"currency,omitempty"`
+	// DisplayUnits is set when the report has display unit columns, in the units of Units.
+	DisplayUnits bool                        `json:"display_units,omitempty"`
+	Units        map[string]client.DenomUnit `json:"units,omitempty"`
+}
+
+// outputOptions returns the options the run's report was written with.
+func (info checkpointRun) outputOptions() outputOptions {
+
+	opts := outputOptions{currency: info.Currency}
+	if info.DisplayUnits {
+		opts.units = info.Units
+		if opts.units == nil {
+			opts.units = map[string]client.DenomUnit{}
+		}
+	}
+
+	return opts
+}
+
+// complete marks the run complete, recording the options its report is written with.
+func (c *Checkpoint) complete(cfg *Config, opts outputOptions) error {
+
+	run, err := json.Marshal(checkpointRun{
+		ChainID:      cfg.ChainID,
+		StartTime:    cfg.StartTime.UTC(),
+		EndTime:      cfg.EndTime.UTC(),
+		Complete:     true,
+		Currency:     opts.currency,
+		DisplayUnits: opts.units != nil,
+		Units:        opts.units,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		rb, err := tx.CreateBucketIfNotExists(runKey(cfg))
+		if err != nil {
+			return err
+		}
+		return rb.Put(runInfoKey, run)
+	})
+}
+
+// put stores the value and marks the run incomplete until it completes again.
+func (c *Checkpoint) put(cfg *Config, bucket, key, value []byte) error {
+
+	run, err := json.Marshal(checkpointRun{ChainID: cfg.ChainID, StartTime: cfg.StartTime.UTC(), EndTime: cfg.EndTime.UTC()})
+	if err != nil {
+		return err
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		rb, err := tx.CreateBucketIfNotExists(runKey(cfg))
+		if err != nil {
+			return err
+		}
+		if err := rb.Put(runInfoKey, run); err != nil {
+			return err
+		}
+		b, err := rb.CreateBucketIfNotExists(bucket)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, value)
+	})
+}
+
+func bucketValue(tx *bolt.Tx, run, bucket, key []byte) []byte {
+
+	rb := tx.Bucket(run)
+	if rb == nil {
+		return nil
+	}
+
+	b := rb.Bucket(bucket)
+	if b == nil {
+		return nil
+	}
+
+	// Values are only valid for the life of the transaction.
+	v := b.Get(key)
+	if v == nil {
+		return nil
+	}
+
+	return append([]byte{}, v...)
+}
+
+func nonNilAmounts(amounts client.Coins) client.Coins {
+	if amounts == nil {
+		return client.Coins{}
+	}
+	return amounts
+}
+
+func nonNilValidatorAmounts(amounts map[string]client.Coins) map[string]client.Coins {
+	if amounts == nil {
+		return map[string]client.Coins{}
+	}
+	return amounts
+}
@@ -0,0 +1,269 @@
+package report
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// diffKeyColumns align the rows of two reports. The denom comes last, as totals are per denom.
+var diffKeyColumns = []string{"account", "date", "validator", "denom"}
+
+// Kinds of DiffRow.
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+	DiffTotal   = "total"
+)
+
+// DiffRow is the change of one value between two reports. Totals are per denom and have no other key.
+type DiffRow struct {
+	Kind   string
+	Key    []string
+	Column string
+	Old    *big.Rat
+	New    *big.Rat
+	Delta  *big.Rat
+}
+
+// DiffColumn is a column of the values of a single denom.
+type DiffColumn struct {
+	Column string
+	Denom  string
+}
+
+// Diff is the difference between two reports, missing rows counting as zero.
+type Diff struct {
+	Rows []DiffRow
+	// Totals has a row per column and denom, ordered by column and then denom.
+	Totals []DiffRow
+	// MaxDeltas is the largest absolute delta of each column and denom.
+	MaxDeltas map[DiffColumn]*big.Rat
+}
+
+// DiffTables compares the numeric columns of the old report a and the new report b, aligning rows by key.
+func DiffTables(a, b Table) (Diff, error) {
+
+	oldRows, err := keyedRows(a)
+	if err != nil {
+		return Diff{}, fmt.Errorf("old report: %w", err)
+	}
+	newRows, err := keyedRows(b)
+	if err != nil {
+		return Diff{}, fmt.Errorf("new report: %w", err)
+	}
+
+	columns := numericColumns(a, b)
+
+	keys := make([]string, 0, len(oldRows)+len(newRows))
+	for k := range oldRows {
+		keys = append(keys, k)
+	}
+	for k := range newRows {
+		if _, ok := oldRows[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	d := Diff{MaxDeltas: map[DiffColumn]*big.Rat{}}
+	oldTotals := map[DiffColumn]*big.Rat{}
+	newTotals := map[DiffColumn]*big.Rat{}
+	denoms := map[string]bool{}
+
+	for _, k := range keys {
+		o, inOld := oldRows[k]
+		n, inNew := newRows[k]
+
+		kind := DiffChanged
+		if !inOld {
+			kind = DiffAdded
+		} else if !inNew {
+			kind = DiffRemoved
+		}
+
+		key := o.key
+		if !inOld {
+			key = n.key
+		}
+		denom := key[len(key)-1]
+		if !denoms[denom] {
+			denoms[denom] = true
+			for _, c := range columns {
+				dc := DiffColumn{Column: c, Denom: denom}
+				oldTotals[dc], newTotals[dc], d.MaxDeltas[dc] = new(big.Rat), new(big.Rat), new(big.Rat)
+			}
+		}
+
+		for _, c := range columns {
+			dc := DiffColumn{Column: c, Denom: denom}
+			ov, nv := o.value(c), n.value(c)
+			oldTotals[dc].Add(oldTotals[dc], ov)
+			newTotals[dc].Add(newTotals[dc], nv)
+
+			delta := new(big.Rat).Sub(nv, ov)
+			if delta.Sign() == 0 && kind == DiffChanged {
+				continue
+			}
+			if abs := new(big.Rat).Abs(delta); abs.Cmp(d.MaxDeltas[dc]) > 0 {
+				d.MaxDeltas[dc] = abs
+			}
+
+			d.Rows = append(d.Rows, DiffRow{Kind: kind, Key: key, Column: c, Old: ov, New: nv, Delta: delta})
+		}
+	}
+
+	sortedDenoms := make([]string, 0, len(denoms))
+	for denom := range denoms {
+		sortedDenoms = append(sortedDenoms, denom)
+	}
+	sort.Strings(sortedDenoms)
+
+	for _, c := range columns {
+		for _, denom := range sortedDenoms {
+			dc := DiffColumn{Column: c, Denom: denom}
+			key := make([]string, len(diffKeyColumns))
+			key[len(key)-1] = denom
+			d.Totals = append(d.Totals, DiffRow{
+				Kind:   DiffTotal,
+				Key:    key,
+				Column: c,
+				Old:    oldTotals[dc],
+				New:    newTotals[dc],
+				Delta:  new(big.Rat).Sub(newTotals[dc], oldTotals[dc]),
+			})
+		}
+	}
+
+	return d, nil
+}
+
+// Table lays the diff out for writing, totals last.
+func (d Diff) Table() Table {
+
+	headers := append([]string{"change"}, diffKeyColumns...)
+	t := Table{Headers: append(headers, "column", "old", "new", "delta")}
+
+	for _, r := range append(append([]DiffRow{}, d.Rows...), d.Totals...) {
+		row := append([]string{r.Kind}, r.Key...)
+		t.Rows = append(t.Rows, append(row, r.Column, ratString(r.Old), ratString(r.New), ratString(r.Delta)))
+	}
+
+	return t
+}
+
+type keyedRow struct {
+	key    []string
+	values map[string]string
+}
+
+// value returns the column's value, zero when the row or value is missing.
+func (r keyedRow) value(column string) *big.Rat {
+	v, ok := new(big.Rat).SetString(r.values[column])
+	if !ok {
+		return new(big.Rat)
+	}
+	return v
+}
+
+func keyedRows(t Table) (map[string]keyedRow, error) {
+
+	index := map[string]int{}
+	for i, h := range t.Headers {
+		index[h] = i
+	}
+	for _, c := range diffKeyColumns {
+		if _, ok := index[c]; !ok {
+			return nil, fmt.Errorf("missing %s column", c)
+		}
+	}
+
+	rows := map[string]keyedRow{}
+	for _, row := range t.Rows {
+		key := make([]string, len(diffKeyColumns))
+		for i, c := range diffKeyColumns {
+			key[i] = row[index[c]]
+		}
+
+		values := map[string]string{}
+		for i, h := range t.Headers {
+			values[h] = row[i]
+		}
+
+		k := strings.Join(key, "\x00")
+		if _, ok := rows[k]; ok {
+			return nil, fmt.Errorf("duplicate row for %s", strings.Join(key, ", "))
+		}
+		rows[k] = keyedRow{key: key, values: values}
+	}
+
+	return rows, nil
+}
+
+// numericColumns returns the columns of a that b also has, holding only numbers in both.
+func numericColumns(a, b Table) []string {
+
+	inNew := map[string]bool{}
+	for _, h := range b.Headers {
+		inNew[h] = true
+	}
+
+	var columns []string
+	for _, h := range a.Headers {
+		if inNew[h] && isNumericColumn(a, h) && isNumericColumn(b, h) && !isKeyColumn(h) {
+			columns = append(columns, h)
+		}
+	}
+
+	return columns
+}
+
+func isKeyColumn(column string) bool {
+	for _, c := range diffKeyColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumericColumn(t Table, column string) bool {
+
+	i := -1
+	for j, h := range t.Headers {
+		if h == column {
+			i = j
+		}
+	}
+
+	for _, row := range t.Rows {
+		if row[i] == "" {
+			continue
+		}
+		if _, ok := new(big.Rat).SetString(row[i]); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ratString formats the value as an exact decimal when it has one and as a fraction otherwise.
+func ratString(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	for prec := 1; prec <= 36; prec++ {
+		if s := r.FloatString(prec); ratEquals(s, r) {
+			return s
+		}
+	}
+	return r.RatString()
+}
+
+func ratEquals(s string, r *big.Rat) bool {
+	v, ok := new(big.Rat).SetString(s)
+	return ok && v.Cmp(r) == 0
+}
@@ -0,0 +1,53 @@
+package report
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestDiffTablesTotalsByDenom(t *testing.T) {
+
+	headers := []string{"account", "date", "validator", "denom", "gross_rewards"}
+	a := Table{Headers: headers, Rows: [][]string{
+		{"cosmos1abc", "2021-01", "valA", "uatom", "1000"},
+		{"cosmos1abc", "2021-01", "valA", "uosmo", "10"},
+		{"cosmos1abc", "2021-01", "valB", "uatom", "500"},
+	}}
+	b := Table{Headers: headers, Rows: [][]string{
+		{"cosmos1abc", "2021-01", "valA", "uatom", "1200"},
+		{"cosmos1abc", "2021-01", "valA", "uosmo", "7"},
+		{"cosmos1abc", "2021-01", "valB", "uatom", "400"},
+	}}
+
+	d, err := DiffTables(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type total struct{ denom, old, new, delta string }
+	var gotTotals []total
+	for _, r := range d.Totals {
+		if r.Column != "gross_rewards" {
+			t.Errorf("total of column %s, want gross_rewards only", r.Column)
+		}
+		gotTotals = append(gotTotals, total{r.Key[len(r.Key)-1], ratString(r.Old), ratString(r.New), ratString(r.Delta)})
+	}
+	wantTotals := []total{{"uatom", "1500", "1600", "100"}, {"uosmo", "10", "7", "-3"}}
+	if !reflect.DeepEqual(gotTotals, wantTotals) {
+		t.Errorf("totals = %v, want %v", gotTotals, wantTotals)
+	}
+
+	wantMax := map[DiffColumn]*big.Rat{
+		{Column: "gross_rewards", Denom: "uatom"}: big.NewRat(200, 1),
+		{Column: "gross_rewards", Denom: "uosmo"}: big.NewRat(3, 1),
+	}
+	if len(d.MaxDeltas) != len(wantMax) {
+		t.Errorf("max deltas of %d columns, want %d", len(d.MaxDeltas), len(wantMax))
+	}
+	for dc, want := range wantMax {
+		if got := d.MaxDeltas[dc]; got == nil || got.Cmp(want) != 0 {
+			t.Errorf("max delta of %v = %v, want %v", dc, got, want)
+		}
+	}
+}
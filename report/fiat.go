@@ -0,0 +1,66 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/figment-networks/cosmos-extract/client"
+	"github.com/figment-networks/cosmos-extract/pricing"
+)
+
+// fiatPrecision is the number of decimals fiat values are written with.
+const fiatPrecision = 2
+
+// fiatAmounts are fiat values by validator and denom.
+type fiatAmounts map[string]map[string]*big.Rat
+
+func (fa fiatAmounts) add(validator, denom string, value *big.Rat) {
+	if _, ok := fa[validator]; !ok {
+		fa[validator] = map[string]*big.Rat{}
+	}
+	if sum, ok := fa[validator][denom]; ok {
+		sum.Add(sum, value)
+	} else {
+		fa[validator][denom] = new(big.Rat).Set(value)
+	}
+}
+
+// valueRewards values every reward entry and its fee at the price of the day it was credited.
+func valueRewards(ctx context.Context, prices pricing.PriceSource, units map[string]client.DenomUnit, entries []client.RewardEntry) (rewards, fees fiatAmounts, err error) {
+
+	rewards = fiatAmounts{}
+	fees = fiatAmounts{}
+	for _, entry := range entries {
+		for denom, amount := range entry.Amount {
+			unit, ok := units[denom]
+			if !ok {
+				return nil, nil, fmt.Errorf("no display unit known for %s to apply its price to; add it to the denom units", denom)
+			}
+
+			price, err := prices.Price(ctx, denom, entry.Time)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			// Prices are per display unit and amounts in base units.
+			scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(unit.Exponent)), nil)
+			perBase := new(big.Rat).Quo(price, new(big.Rat).SetInt(scale))
+
+			rewards.add(entry.Validator, denom, new(big.Rat).Mul(perBase, new(big.Rat).SetInt(amount)))
+			if fee := entry.Fee[denom]; fee != nil {
+				fees.add(entry.Validator, denom, new(big.Rat).Mul(perBase, new(big.Rat).SetInt(fee)))
+			}
+		}
+	}
+
+	return rewards, fees, nil
+}
+
+// fiatString formats a fiat value, leaving nil values empty.
+func fiatString(value *big.Rat) string {
+	if value == nil {
+		return ""
+	}
+	return value.FloatString(fiatPrecision)
+}
@@ -0,0 +1,204 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/source"
+	bolt "go.etcd.io/bbolt"
+)
+
+// LoadTable reads a report in the format, or in the format of the path's extension if it is empty.
+func LoadTable(path, format string) (Table, error) {
+
+	if format == "" {
+		format = FormatFromPath(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Table{}, err
+	}
+	defer f.Close()
+
+	var t Table
+	switch format {
+	case FormatCSV:
+		t, err = readCSV(f)
+	case FormatJSON, FormatNDJSON:
+		// Both are a sequence of objects, optionally wrapped in an array.
+		t, err = readJSONObjects(f)
+	case FormatParquet:
+		var pf source.ParquetFile
+		if pf, err = local.NewLocalFileReader(path); err == nil {
+			t, err = readParquet(pf)
+			pf.Close()
+		}
+	case FormatXLSX:
+		t, err = readXLSX(path)
+	default:
+		return Table{}, fmt.Errorf("reading %s reports is not supported", format)
+	}
+	if err != nil {
+		return Table{}, fmt.Errorf("could not read %s: %w", path, err)
+	}
+
+	return t, nil
+}
+
+func readCSV(r io.Reader) (Table, error) {
+
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return Table{}, err
+	}
+	if len(records) == 0 {
+		return Table{}, errors.New("no header")
+	}
+
+	return Table{Headers: records[0], Rows: records[1:]}, nil
+}
+
+// readJSONObjects reads objects of string values, the first object's fields being the headers.
+func readJSONObjects(r io.Reader) (Table, error) {
+
+	dec := json.NewDecoder(r)
+	var t Table
+	columns := map[string]int{}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return t, nil
+		}
+		if err != nil {
+			return Table{}, err
+		}
+
+		// Skip the brackets of a JSON array.
+		if d, ok := tok.(json.Delim); !ok || d != '{' {
+			continue
+		}
+
+		row := make([]string, len(t.Headers))
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return Table{}, err
+			}
+			var value string
+			if err := dec.Decode(&value); err != nil {
+				return Table{}, err
+			}
+
+			key := keyTok.(string)
+			i, ok := columns[key]
+			if !ok {
+				if len(t.Rows) > 0 {
+					return Table{}, fmt.Errorf("field %q is missing from earlier rows", key)
+				}
+				i = len(t.Headers)
+				columns[key] = i
+				t.Headers = append(t.Headers, key)
+				row = append(row, "")
+			}
+			row[i] = value
+		}
+		if _, err := dec.Token(); err != nil {
+			return Table{}, err
+		}
+
+		t.Rows = append(t.Rows, row)
+	}
+}
+
+// LoadCheckpointTable rebuilds the report of the only complete run, of the chain ID if set, in a store.
+func LoadCheckpointTable(path, chainID string) (Table, error) {
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: checkpointTimeout})
+	if err != nil {
+		return Table{}, fmt.Errorf("could not open checkpoint store %s: %w", path, err)
+	}
+	defer db.Close()
+
+	type indexedResult struct {
+		index  int
+		result durationResult
+	}
+	byAccount := map[string][]indexedResult{}
+	var runs []string
+	var found checkpointRun
+
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(run []byte, rb *bolt.Bucket) error {
+			var info checkpointRun
+			if v := rb.Get(runInfoKey); v != nil {
+				if err := json.Unmarshal(v, &info); err != nil {
+					return fmt.Errorf("invalid run %s: %w", run, err)
+				}
+			}
+			if chainID != "" && info.ChainID != chainID {
+				return nil
+			}
+			runs = append(runs, fmt.Sprintf("%s from %s to %s", info.ChainID, info.StartTime.Format(time.RFC3339), info.EndTime.Format(time.RFC3339)))
+			found = info
+
+			b := rb.Bucket(resultsBucket)
+			if b == nil {
+				return nil
+			}
+
+			return b.ForEach(func(k, v []byte) error {
+				key := string(k)
+				sep := strings.LastIndex(key, "/")
+				index, err := strconv.Atoi(key[sep+1:])
+				if sep < 0 || err != nil {
+					return fmt.Errorf("invalid result key %q", key)
+				}
+
+				dr, err := decodeCheckpointResult(v)
+				if err != nil {
+					return err
+				}
+
+				acc := key[:sep]
+				byAccount[acc] = append(byAccount[acc], indexedResult{index: index, result: dr})
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return Table{}, fmt.Errorf("could not read checkpoint store %s: %w", path, err)
+	}
+	if len(runs) == 0 && chainID != "" {
+		return Table{}, fmt.Errorf("checkpoint store %s holds no run of %s", path, chainID)
+	}
+	if len(runs) != 1 {
+		return Table{}, fmt.Errorf("checkpoint store %s holds %d runs, expected one: %s", path, len(runs), strings.Join(runs, "; "))
+	}
+	if !found.Complete {
+		return Table{}, fmt.Errorf("run %s in checkpoint store %s is not complete, resume it first", runs[0], path)
+	}
+
+	results := accountResults{}
+	accounts := make([]string, 0, len(byAccount))
+	for acc, indexed := range byAccount {
+		sort.Slice(indexed, func(i, j int) bool { return indexed[i].index < indexed[j].index })
+		for _, ir := range indexed {
+			results[acc] = append(results[acc], ir.result)
+		}
+		accounts = append(accounts, acc)
+	}
+	sort.Strings(accounts)
+
+	return results.reportTable(accounts, found.outputOptions()), nil
+}
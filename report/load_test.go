@@ -0,0 +1,183 @@
+package report
+
+import (
+	"archive/zip"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/figment-networks/cosmos-extract/client"
+)
+
+// sampleReport and sampleSummary are written and loaded back in every format.
+var (
+	sampleReport = Table{
+		Headers: []string{"account", "period", "validator", "amount"},
+		Rows: [][]string{
+			{"cosmos1abc", "2021-01", "cosmosvaloper1xyz", "123456789012345678901234567890"},
+			{"cosmos1abc", "2021-02", "", "0.000001"},
+			{"cosmos1déf", "2021-01", "cosmosvaloper1xyz", "1 <&> 2"},
+		},
+	}
+	sampleSummary = Table{
+		Headers: []string{"account", "total"},
+		Rows:    [][]string{{"cosmos1abc", "123456789012345678901234567890.000001"}, {"cosmos1déf", "3"}},
+	}
+)
+
+func TestLoadTableWrittenReports(t *testing.T) {
+
+	for _, format := range []string{FormatCSV, FormatJSON, FormatNDJSON, FormatParquet, FormatXLSX} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "report."+format)
+			w, err := NewWriter("", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write(path, sampleReport, sampleSummary); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadTable(path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, sampleReport) {
+				t.Errorf("loaded %q, want %q", got, sampleReport)
+			}
+		})
+	}
+}
+
+func TestLoadTableXLSXRowless(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "report.xlsx")
+	if _, err := (xlsxWriter{}).Write(path, Table{Headers: sampleReport.Headers}, sampleSummary); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadTable(path, ""); err == nil {
+		t.Error("expected an error for a workbook with only a summary sheet")
+	}
+}
+
+func TestLoadCheckpointTable(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "checkpoint.db")
+	cfg := &Config{
+		ChainID:   "cosmoshub-4",
+		StartTime: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	results := initAccountResults([]string{"cosmos1abc"})
+	r := initDurationResult(cfg.StartTime, "2021-01")
+	r.validators["valA"] = true
+	r.delegations["valA"] = client.Coins{"uatom": big.NewInt(1000000)}
+	r.rewards["valA"] = client.Coins{"uatom": big.NewInt(1500)}
+	r.fees["valA"] = client.Coins{"uatom": big.NewInt(150)}
+	r.rewardsFiat.add("valA", "uatom", big.NewRat(3, 200))
+	r.feesFiat.add("valA", "uatom", big.NewRat(3, 2000))
+	results["cosmos1abc"] = append(results["cosmos1abc"], r)
+
+	opts := outputOptions{currency: "USD", units: map[string]client.DenomUnit{"uatom": {Display: "atom", Exponent: 6}}}
+
+	checkpoint, err := OpenCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpoint.saveResult(cfg, "cosmos1abc", 0, r); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint.Close()
+
+	if _, err := LoadCheckpointTable(path, ""); err == nil {
+		t.Error("expected an error for a run that did not complete")
+	}
+
+	if checkpoint, err = OpenCheckpoint(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpoint.complete(cfg, opts); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint.Close()
+
+	got, err := LoadCheckpointTable(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := results.reportTable([]string{"cosmos1abc"}, opts); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %q, want %q", got, want)
+	}
+}
+
+// A workbook saved again by a spreadsheet application uses shared strings and rich text.
+func TestLoadTableXLSXSharedStrings(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "saved.xlsx")
+	writeZip(t, path, map[string]string{
+		"_rels/.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="/xl/workbook.xml"/></Relationships>`,
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` +
+			`<sheet name="cosmos1abc" sheetId="1" r:id="rId1"/><sheet name="summary" sheetId="2" r:id="rId2"/>` +
+			`</sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/>` +
+			`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings" Target="sharedStrings.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<si><t>account</t></si><si><t>amount</t></si><si><r><t>cosmos</t></r><r><t>1abc</t></r></si>` +
+			`<si><t>123456789012345678901234567890</t></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="inlineStr"><is><t>note</t></is></c></row>` +
+			`<row r="2"><c r="A2" t="s"><v>2</v></c><c r="B2" t="s"><v>3</v></c></row>` +
+			`<row r="3"><c r="A3" t="s"><v>2</v></c><c r="B3"><v>5</v></c><c r="C3" t="str"><v>x</v></c></row>` +
+			`</sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData/></worksheet>`,
+	})
+
+	got, err := LoadTable(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Table{
+		Headers: []string{"account", "amount", "note"},
+		Rows: [][]string{
+			{"cosmos1abc", "123456789012345678901234567890", ""},
+			{"cosmos1abc", "5", "x"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %q, want %q", got, want)
+	}
+}
+
+func writeZip(t *testing.T, path string, parts map[string]string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
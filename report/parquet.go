@@ -0,0 +1,80 @@
+package report
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// writeParquet writes the table as a Parquet file with a required UTF8 column per header.
+func writeParquet(w io.Writer, t Table) error {
+
+	schema := make([]string, len(t.Headers))
+	for i, h := range t.Headers {
+		schema[i] = fmt.Sprintf("name=%s, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=REQUIRED", h)
+	}
+
+	pw, err := writer.NewCSVWriterFromWriter(schema, w, 1)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range t.Rows {
+		values := make([]interface{}, len(row))
+		for i, value := range row {
+			values[i] = value
+		}
+		if err := pw.Write(values); err != nil {
+			return err
+		}
+	}
+
+	return pw.WriteStop()
+}
+
+// readParquet reads a Parquet report back, one string column per header.
+func readParquet(file source.ParquetFile) (Table, error) {
+
+	pr, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		return Table{}, err
+	}
+	defer pr.ReadStop()
+
+	// The schema is its root followed by the columns.
+	columns := pr.SchemaHandler.ValueColumns
+	if len(pr.SchemaHandler.Infos) != len(columns)+1 {
+		return Table{}, fmt.Errorf("not a flat table")
+	}
+
+	numRows := pr.GetNumRows()
+	t := Table{Rows: make([][]string, numRows)}
+	for c := range columns {
+		name := pr.SchemaHandler.GetExName(c + 1)
+		t.Headers = append(t.Headers, name)
+
+		if numRows == 0 {
+			continue
+		}
+		values, _, _, err := pr.ReadColumnByIndex(int64(c), numRows)
+		if err != nil {
+			return Table{}, fmt.Errorf("column %s: %w", name, err)
+		}
+		if int64(len(values)) != numRows {
+			return Table{}, fmt.Errorf("column %s has %d values, want %d", name, len(values), numRows)
+		}
+
+		for r, v := range values {
+			s, ok := v.(string)
+			if !ok {
+				return Table{}, fmt.Errorf("column %s holds %T values, not strings", name, v)
+			}
+			t.Rows[r] = append(t.Rows[r], s)
+		}
+	}
+
+	return t, nil
+}
@@ -0,0 +1,112 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestWriteParquetRoundTrip(t *testing.T) {
+
+	wide := Table{}
+	for i := 0; i < 20; i++ {
+		wide.Headers = append(wide.Headers, "column_"+strconv.Itoa(i))
+	}
+	for r := 0; r < 3; r++ {
+		row := make([]string, len(wide.Headers))
+		for c := range row {
+			row[c] = fmt.Sprintf("%d.%d", r, c)
+		}
+		wide.Rows = append(wide.Rows, row)
+	}
+
+	tests := []struct {
+		name  string
+		table Table
+	}{
+		{
+			name:  "no rows",
+			table: Table{Headers: []string{"account", "amount"}},
+		},
+		{
+			name: "rows",
+			table: Table{
+				Headers: []string{"account", "validator", "amount"},
+				Rows: [][]string{
+					{"cosmos1abc", "cosmosvaloper1xyz", "123456789012345678901234567890"},
+					{"cosmos1déf", "", "0"},
+				},
+			},
+		},
+		{
+			name:  "wide",
+			table: wide,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeParquet(&buf, tt.table); err != nil {
+				t.Fatal(err)
+			}
+
+			checkParquetSchema(t, buf.Bytes(), tt.table)
+
+			file, err := buffer.NewBufferFile(buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := readParquet(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Headers, tt.table.Headers) {
+				t.Errorf("headers = %v, want %v", got.Headers, tt.table.Headers)
+			}
+			if len(got.Rows) != len(tt.table.Rows) || (len(got.Rows) > 0 && !reflect.DeepEqual(got.Rows, tt.table.Rows)) {
+				t.Errorf("rows = %v, want %v", got.Rows, tt.table.Rows)
+			}
+		})
+	}
+}
+
+// checkParquetSchema checks the file has a required UTF8 column per header and the table's rows.
+func checkParquetSchema(t *testing.T, b []byte, table Table) {
+	t.Helper()
+
+	file, err := buffer.NewBufferFile(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+
+	if n := pr.GetNumRows(); n != int64(len(table.Rows)) {
+		t.Errorf("file has %d rows, want %d", n, len(table.Rows))
+	}
+
+	columns := pr.Footer.GetSchema()[1:]
+	if len(columns) != len(table.Headers) {
+		t.Fatalf("file has %d columns, want %d", len(columns), len(table.Headers))
+	}
+	for i, col := range columns {
+		name := pr.SchemaHandler.GetExName(i + 1)
+		if name != table.Headers[i] {
+			t.Errorf("column %d is %s, want %s", i, name, table.Headers[i])
+		}
+		if col.GetType() != parquet.Type_BYTE_ARRAY || col.GetConvertedType() != parquet.ConvertedType_UTF8 ||
+			col.GetRepetitionType() != parquet.FieldRepetitionType_REQUIRED {
+			t.Errorf("column %s is %s %s %s, want a required UTF8 byte array", name, col.GetRepetitionType(), col.GetType(), col.GetConvertedType())
+		}
+	}
+}
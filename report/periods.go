@@ -2,55 +2,288 @@ package report
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
 	"time"
+)
 
-	"github.com/figment-networks/cosmos-extract/client"
+// Supported values for Config.Granularity. An empty granularity behaves like GranularityMonthly.
+const (
+	GranularityDaily     = "daily"
+	GranularityWeekly    = "weekly"
+	GranularityMonthly   = "monthly"
+	GranularityQuarterly = "quarterly"
+	GranularityYearly    = "yearly"
+	GranularityCustom    = "custom"
 )
 
 type period struct {
 	startTime     time.Time
 	nextStartTime time.Time
 	endHeight     uint64
+	// The chain segment the end height belongs to.
+	segment Segment
+	label   string
+}
+
+// PeriodHeight is a period of the run with the last height before its end.
+type PeriodHeight struct {
+	Label     string
+	StartTime time.Time
+	EndTime   time.Time
+	ChainID   string
+	EndHeight uint64
+}
+
+func (r *runner) Heights(ctx context.Context, cfg *Config) ([]PeriodHeight, error) {
+
+	if cfg == nil {
+		return nil, errors.New("no config provided")
+	}
+
+	segments, err := r.segments(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	periods, err := r.buildOrderedPeriods(ctx, cfg, segments)
+	if err != nil {
+		return nil, err
+	}
+
+	heights := make([]PeriodHeight, len(periods))
+	for i, p := range periods {
+		heights[i] = PeriodHeight{
+			Label:     p.label,
+			StartTime: p.startTime,
+			EndTime:   p.nextStartTime,
+			ChainID:   p.segment.ChainID,
+			EndHeight: p.endHeight,
+		}
+	}
+
+	return heights, nil
 }
 
 func (r *runner) buildOrderedPeriods(
 	ctx context.Context,
-	startTime,
-	endTime time.Time,
+	cfg *Config,
+	segments []Segment,
 ) (periods []period, err error) {
 
-	// We want to get the last height for each of the months that fall within the provided time range,
-	// regardless of the specific times. Start by calculating the number of months we need heights for.
-	startYear := startTime.Year()
-	startMonth := int(startTime.Month())
-	endYear := endTime.Year()
-	endMonth := int(endTime.Month())
-	numMonths := (endYear-startYear)*12 + endMonth - startMonth + 1
-
-	periods = make([]period, numMonths)
-	for i := 0; i < numMonths; i++ {
-		// Use the current month to get the first time of the next month. We will pass this "before time"
-		// as an argument to indicate we want the last height that occurred before this time.
-		currMonthStartTime := time.Date(startYear, time.Month(startMonth+i), 1, 0, 0, 0, 0, time.UTC)
-		nextMonthStartTime := time.Date(startYear, time.Month(startMonth+i+1), 1, 0, 0, 0, 0, time.UTC)
-		req := client.LastHeightBeforeReq{
-			Network:    network,
-			ChainID:    chainID,
-			BeforeTime: nextMonthStartTime,
-		}
+	bounds, err := periodBounds(cfg)
+	if err != nil {
+		return
+	}
 
+	periods = make([]period, len(bounds))
+	for i, b := range bounds {
+		// We pass the start of the next period as the "before time" to indicate we want the last height
+		// that occurred in this period.
+		var seg Segment
 		var height uint64
-		height, err = r.client.GetLastHeightBefore(ctx, req)
+		seg, height, err = r.periodEndHeight(ctx, cfg, segments, b)
 		if err != nil {
 			return
 		}
 
 		periods[i] = period{
-			startTime:     currMonthStartTime,
-			nextStartTime: nextMonthStartTime,
+			startTime:     b.startTime,
+			nextStartTime: b.nextStartTime,
 			endHeight:     height,
+			segment:       seg,
+			label:         periodLabel(cfg.Granularity, b.startTime.In(location(cfg))),
 		}
 	}
 
 	return
 }
+
+// periodEndHeight returns the last height of the period and the segment it belongs to, reusing the
+// checkpointed height when resuming.
+func (r *runner) periodEndHeight(ctx context.Context, cfg *Config, segments []Segment, b periodBound) (Segment, uint64, error) {
+
+	if cfg.Checkpoint != nil && cfg.Resume {
+		cp, ok, err := cfg.Checkpoint.loadPeriod(cfg, b)
+		if err != nil {
+			return Segment{}, 0, err
+		}
+		if ok {
+			for _, seg := range segments {
+				if seg.ChainID == cp.ChainID {
+					return seg, cp.EndHeight, nil
+				}
+			}
+		}
+	}
+
+	seg, height, err := lastHeightBefore(ctx, segments, cfg.Network, b.nextStartTime)
+	if err != nil {
+		return seg, 0, err
+	}
+
+	if cfg.Checkpoint != nil {
+		cp := checkpointPeriod{EndHeight: height, ChainID: seg.ChainID}
+		if err := cfg.Checkpoint.savePeriod(cfg, b, cp); err != nil {
+			return seg, 0, fmt.Errorf("could not checkpoint period: %w", err)
+		}
+	}
+
+	return seg, height, nil
+}
+
+type periodBound struct {
+	startTime     time.Time
+	nextStartTime time.Time
+}
+
+// periodBounds returns the chronologically ordered periods that fall within the provided time range.
+// Whole periods are returned, regardless of the specific times of the start and end, unless partial
+// periods are enabled.
+func periodBounds(cfg *Config) (bounds []periodBound, err error) {
+
+	if cfg.Granularity == GranularityCustom {
+		bounds, err = customPeriodBounds(cfg.Boundaries, cfg.StartTime, cfg.EndTime)
+	} else {
+		bounds, err = calendarPeriodBounds(cfg)
+	}
+	if err != nil || !cfg.PartialPeriods {
+		return
+	}
+
+	return clipPeriodBounds(bounds, cfg.StartTime, cfg.EndTime), nil
+}
+
+// clipPeriodBounds cuts the first and last periods down to the exact time range. Periods left empty by
+// the range ending on a boundary are dropped.
+func clipPeriodBounds(bounds []periodBound, startTime, endTime time.Time) []periodBound {
+
+	clipped := make([]periodBound, 0, len(bounds))
+	for _, b := range bounds {
+		if b.startTime.Before(startTime) {
+			b.startTime = startTime
+		}
+		if b.nextStartTime.After(endTime) {
+			b.nextStartTime = endTime
+		}
+		if b.startTime.Before(b.nextStartTime) {
+			clipped = append(clipped, b)
+		}
+	}
+
+	return clipped
+}
+
+func calendarPeriodBounds(cfg *Config) ([]periodBound, error) {
+
+	loc := location(cfg)
+	truncate, next, err := granularityFuncs(cfg.Granularity, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Boundaries are calendar dates in the report's time zone. Days and months are stepped through
+	// with AddDate so periods around DST transitions are 23 or 25 hours long rather than shifted.
+	var bounds []periodBound
+	for start := truncate(cfg.StartTime.In(loc)); !start.After(cfg.EndTime); start = next(start) {
+		bounds = append(bounds, periodBound{startTime: start, nextStartTime: next(start)})
+	}
+
+	return bounds, nil
+}
+
+// granularityFuncs returns a function that truncates a time to the start of its period in the location
+// and one that returns the start of the following period.
+func granularityFuncs(granularity string, loc *time.Location) (truncate, next func(time.Time) time.Time, err error) {
+
+	switch granularity {
+	case GranularityDaily:
+		truncate = func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	case GranularityWeekly:
+		// ISO weeks start on Monday.
+		truncate = func(t time.Time) time.Time {
+			daysSinceMonday := (int(t.Weekday()) + 6) % 7
+			return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, loc)
+		}
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	case "", GranularityMonthly:
+		truncate = func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		next = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	case GranularityQuarterly:
+		truncate = func(t time.Time) time.Time {
+			firstMonth := (t.Month()-1)/3*3 + 1
+			return time.Date(t.Year(), firstMonth, 1, 0, 0, 0, 0, loc)
+		}
+		next = func(t time.Time) time.Time { return t.AddDate(0, 3, 0) }
+	case GranularityYearly:
+		truncate = func(t time.Time) time.Time {
+			return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, loc)
+		}
+		next = func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }
+	default:
+		err = fmt.Errorf("unknown granularity %q", granularity)
+	}
+
+	return
+}
+
+// customPeriodBounds returns the periods between consecutive boundaries that overlap the time range.
+func customPeriodBounds(boundaries []time.Time, startTime, endTime time.Time) ([]periodBound, error) {
+
+	if len(boundaries) < 2 {
+		return nil, errors.New("custom granularity needs at least two boundaries")
+	}
+
+	sorted := make([]time.Time, len(boundaries))
+	copy(sorted, boundaries)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	var bounds []periodBound
+	for i := 0; i < len(sorted)-1; i++ {
+		if !sorted[i].Before(sorted[i+1]) {
+			return nil, fmt.Errorf("duplicate boundary %s", sorted[i])
+		}
+		if !sorted[i+1].After(startTime) || sorted[i].After(endTime) {
+			continue
+		}
+		bounds = append(bounds, periodBound{startTime: sorted[i], nextStartTime: sorted[i+1]})
+	}
+
+	if len(bounds) == 0 {
+		return nil, errors.New("no custom periods fall within the time range")
+	}
+
+	return bounds, nil
+}
+
+// periodLabel formats the start of a period the way it is presented in the report.
+func periodLabel(granularity string, start time.Time) string {
+
+	switch granularity {
+	case GranularityDaily, GranularityCustom:
+		return start.Format("2006-01-02")
+	case GranularityWeekly:
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case GranularityQuarterly:
+		return fmt.Sprintf("%d-Q%d", start.Year(), (start.Month()-1)/3+1)
+	case GranularityYearly:
+		return start.Format("2006")
+	default:
+		return start.Format("2006-01")
+	}
+}
+
+// location returns the time zone period boundaries are computed in.
+func location(cfg *Config) *time.Location {
+	if cfg.Location == nil {
+		return time.UTC
+	}
+	return cfg.Location
+}
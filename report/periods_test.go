@@ -0,0 +1,254 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	// The DST cases don't depend on the zone database of the host.
+	_ "time/tzdata"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestPeriodBounds(t *testing.T) {
+
+	newYork := mustLoadLocation(t, "America/New_York")
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+
+	date := func(loc *time.Location, year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name string
+		cfg  Config
+		// want holds the UTC start and end of every period followed by its label.
+		want [][3]string
+	}{
+		{
+			// DST starts on March 14, so April starts an hour earlier in UTC than the other months.
+			name: "monthly New York over DST start",
+			cfg: Config{
+				Granularity: GranularityMonthly,
+				Location:    newYork,
+				StartTime:   date(newYork, 2021, time.February, 10, 0),
+				EndTime:     date(newYork, 2021, time.March, 20, 0),
+			},
+			want: [][3]string{
+				{"2021-02-01T05:00:00Z", "2021-03-01T05:00:00Z", "2021-02"},
+				{"2021-03-01T05:00:00Z", "2021-04-01T04:00:00Z", "2021-03"},
+			},
+		},
+		{
+			// The week of the DST start is an hour short.
+			name: "weekly New York over DST start",
+			cfg: Config{
+				Granularity: GranularityWeekly,
+				Location:    newYork,
+				StartTime:   date(newYork, 2021, time.March, 10, 12),
+				EndTime:     date(newYork, 2021, time.March, 16, 0),
+			},
+			want: [][3]string{
+				{"2021-03-08T05:00:00Z", "2021-03-15T04:00:00Z", "2021-W10"},
+				{"2021-03-15T04:00:00Z", "2021-03-22T04:00:00Z", "2021-W11"},
+			},
+		},
+		{
+			name: "daily Berlin over DST start",
+			cfg: Config{
+				Granularity: GranularityDaily,
+				Location:    berlin,
+				StartTime:   date(berlin, 2021, time.March, 27, 12),
+				EndTime:     date(berlin, 2021, time.March, 28, 12),
+			},
+			want: [][3]string{
+				{"2021-03-26T23:00:00Z", "2021-03-27T23:00:00Z", "2021-03-27"},
+				{"2021-03-27T23:00:00Z", "2021-03-28T22:00:00Z", "2021-03-28"},
+			},
+		},
+		{
+			// The day DST ends is 25 hours long.
+			name: "daily Berlin over DST end",
+			cfg: Config{
+				Granularity: GranularityDaily,
+				Location:    berlin,
+				StartTime:   date(berlin, 2021, time.October, 31, 1),
+				EndTime:     date(berlin, 2021, time.October, 31, 23),
+			},
+			want: [][3]string{
+				{"2021-10-30T22:00:00Z", "2021-10-31T23:00:00Z", "2021-10-31"},
+			},
+		},
+		{
+			name: "monthly Berlin over DST end",
+			cfg: Config{
+				Granularity: GranularityMonthly,
+				Location:    berlin,
+				StartTime:   date(berlin, 2021, time.October, 1, 0),
+				EndTime:     date(berlin, 2021, time.November, 15, 0),
+			},
+			want: [][3]string{
+				{"2021-09-30T22:00:00Z", "2021-10-31T23:00:00Z", "2021-10"},
+				{"2021-10-31T23:00:00Z", "2021-11-30T23:00:00Z", "2021-11"},
+			},
+		},
+		{
+			// 2020 has 53 ISO weeks; its last one ends in 2021.
+			name: "weekly ISO week 53",
+			cfg: Config{
+				Granularity: GranularityWeekly,
+				StartTime:   date(time.UTC, 2021, time.January, 1, 0),
+				EndTime:     date(time.UTC, 2021, time.January, 5, 0),
+			},
+			want: [][3]string{
+				{"2020-12-28T00:00:00Z", "2021-01-04T00:00:00Z", "2020-W53"},
+				{"2021-01-04T00:00:00Z", "2021-01-11T00:00:00Z", "2021-W01"},
+			},
+		},
+		{
+			name: "quarterly",
+			cfg: Config{
+				Granularity: GranularityQuarterly,
+				StartTime:   date(time.UTC, 2021, time.February, 1, 0),
+				EndTime:     date(time.UTC, 2021, time.April, 1, 0),
+			},
+			want: [][3]string{
+				{"2021-01-01T00:00:00Z", "2021-04-01T00:00:00Z", "2021-Q1"},
+				{"2021-04-01T00:00:00Z", "2021-07-01T00:00:00Z", "2021-Q2"},
+			},
+		},
+		{
+			name: "yearly",
+			cfg: Config{
+				Granularity: GranularityYearly,
+				StartTime:   date(time.UTC, 2021, time.June, 1, 0),
+				EndTime:     date(time.UTC, 2021, time.July, 1, 0),
+			},
+			want: [][3]string{
+				{"2021-01-01T00:00:00Z", "2022-01-01T00:00:00Z", "2021"},
+			},
+		},
+		{
+			// Fiscal years starting in April, given in any order.
+			name: "custom fiscal years",
+			cfg: Config{
+				Granularity: GranularityCustom,
+				Boundaries: []time.Time{
+					date(time.UTC, 2021, time.April, 1, 0),
+					date(time.UTC, 2020, time.April, 1, 0),
+					date(time.UTC, 2022, time.April, 1, 0),
+					date(time.UTC, 2019, time.April, 1, 0),
+				},
+				StartTime: date(time.UTC, 2020, time.June, 1, 0),
+				EndTime:   date(time.UTC, 2021, time.May, 1, 0),
+			},
+			want: [][3]string{
+				{"2020-04-01T00:00:00Z", "2021-04-01T00:00:00Z", "2020-04-01"},
+				{"2021-04-01T00:00:00Z", "2022-04-01T00:00:00Z", "2021-04-01"},
+			},
+		},
+		{
+			name: "partial first and last periods",
+			cfg: Config{
+				Granularity:    GranularityMonthly,
+				PartialPeriods: true,
+				StartTime:      date(time.UTC, 2021, time.January, 15, 0),
+				EndTime:        date(time.UTC, 2021, time.February, 10, 0),
+			},
+			want: [][3]string{
+				{"2021-01-15T00:00:00Z", "2021-02-01T00:00:00Z", "2021-01"},
+				{"2021-02-01T00:00:00Z", "2021-02-10T00:00:00Z", "2021-02"},
+			},
+		},
+		{
+			// Whole periods include the one starting at the end, partial ones have nothing left of it.
+			name: "whole periods with the range ending on a boundary",
+			cfg: Config{
+				Granularity: GranularityMonthly,
+				StartTime:   date(time.UTC, 2021, time.January, 15, 0),
+				EndTime:     date(time.UTC, 2021, time.February, 1, 0),
+			},
+			want: [][3]string{
+				{"2021-01-01T00:00:00Z", "2021-02-01T00:00:00Z", "2021-01"},
+				{"2021-02-01T00:00:00Z", "2021-03-01T00:00:00Z", "2021-02"},
+			},
+		},
+		{
+			name: "partial periods with the range ending on a boundary",
+			cfg: Config{
+				Granularity:    GranularityMonthly,
+				PartialPeriods: true,
+				StartTime:      date(time.UTC, 2021, time.January, 15, 0),
+				EndTime:        date(time.UTC, 2021, time.February, 1, 0),
+			},
+			want: [][3]string{
+				{"2021-01-15T00:00:00Z", "2021-02-01T00:00:00Z", "2021-01"},
+			},
+		},
+		{
+			name: "partial periods in New York",
+			cfg: Config{
+				Granularity:    GranularityDaily,
+				PartialPeriods: true,
+				Location:       newYork,
+				StartTime:      date(newYork, 2021, time.November, 6, 18),
+				EndTime:        date(newYork, 2021, time.November, 7, 12),
+			},
+			want: [][3]string{
+				{"2021-11-06T22:00:00Z", "2021-11-07T04:00:00Z", "2021-11-06"},
+				{"2021-11-07T04:00:00Z", "2021-11-07T17:00:00Z", "2021-11-07"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bounds, err := periodBounds(&tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got [][3]string
+			for _, b := range bounds {
+				got = append(got, [3]string{
+					b.startTime.UTC().Format(time.RFC3339),
+					b.nextStartTime.UTC().Format(time.RFC3339),
+					periodLabel(tt.cfg.Granularity, b.startTime.In(location(&tt.cfg))),
+				})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("periods = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodBoundsErrors(t *testing.T) {
+
+	jan := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"unknown granularity", Config{Granularity: "hourly", StartTime: jan, EndTime: jan}},
+		{"one boundary", Config{Granularity: GranularityCustom, Boundaries: []time.Time{jan}, StartTime: jan, EndTime: jan}},
+		{"duplicate boundaries", Config{Granularity: GranularityCustom, Boundaries: []time.Time{jan, jan, jan.AddDate(1, 0, 0)}, StartTime: jan, EndTime: jan}},
+		{"boundaries outside the range", Config{Granularity: GranularityCustom, Boundaries: []time.Time{jan, jan.AddDate(0, 1, 0)}, StartTime: jan.AddDate(1, 0, 0), EndTime: jan.AddDate(2, 0, 0)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := periodBounds(&tt.cfg); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
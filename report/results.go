@@ -1,27 +1,116 @@
 package report
 
 import (
-	"encoding/csv"
 	"math/big"
-	"os"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/figment-networks/cosmos-extract/client"
 )
 
 type accountResults map[string][]durationResult
 
 type durationResult struct {
 	duration time.Time
+	label    string
 	// Tracks unique validators from all of the fields below.
 	validators map[string]bool
-	// Validator address as the key.
-	delegations map[string]*big.Int
-	rewards     map[string]*big.Int
-	fees        map[string]*big.Int
+	// Validator address as the key, amounts by denom as the value.
+	delegations map[string]client.Coins
+	unbonding   map[string]client.Coins
+	// Balances being redelegated to the validator and away from it.
+	redelegationsIn  map[string]client.Coins
+	redelegationsOut map[string]client.Coins
+	rewards          map[string]client.Coins
+	fees             map[string]client.Coins
+	// Rewards accrued by the end of the period but not withdrawn yet.
+	pendingRewards map[string]client.Coins
+	// Fiat values of the rewards and fees, only set when a price source is configured.
+	rewardsFiat fiatAmounts
+	feesFiat    fiatAmounts
+	// Everything the account holds at the end of the period, by denom.
+	holdings holdings
+}
+
+type holdings struct {
+	liquid           client.Coins
+	delegated        client.Coins
+	unbonding        client.Coins
+	unclaimedRewards client.Coins
+}
+
+func initHoldings() holdings {
+	return holdings{
+		liquid:           client.Coins{},
+		delegated:        client.Coins{},
+		unbonding:        client.Coins{},
+		unclaimedRewards: client.Coins{},
+	}
+}
+
+// denoms returns the sorted denoms the account holds anything of.
+func (h holdings) denoms() []string {
+	all := client.Coins{}
+	for _, c := range []client.Coins{h.liquid, h.delegated, h.unbonding, h.unclaimedRewards} {
+		all.AddCoins(c)
+	}
+	return all.Denoms()
+}
+
+// total returns the sum of all holdings of the denom.
+func (h holdings) total(denom string) *big.Int {
+	total := big.NewInt(0)
+	for _, c := range []client.Coins{h.liquid, h.delegated, h.unbonding, h.unclaimedRewards} {
+		if v := c[denom]; v != nil {
+			total = total.Add(total, v)
+		}
+	}
+	return total
+}
+
+// validatorDenoms returns the sorted denoms of every amount recorded for the validator.
+func (dr durationResult) validatorDenoms(validator string) []string {
+	all := client.Coins{}
+	for _, m := range []map[string]client.Coins{
+		dr.delegations, dr.unbonding, dr.redelegationsIn, dr.redelegationsOut, dr.rewards, dr.fees, dr.pendingRewards,
+	} {
+		all.AddCoins(m[validator])
+	}
+	return all.Denoms()
 }
 
-func (dr durationResult) netRewards(validator string) *big.Int {
+// sortedValidators returns the validators ordered by moniker when given, by address otherwise.
+func (dr durationResult) sortedValidators(monikers map[string]string) []string {
+
+	validators := make([]string, 0, len(dr.validators))
+	for v := range dr.validators {
+		validators = append(validators, v)
+	}
+
+	sort.Slice(validators, func(i, j int) bool {
+		if monikers != nil {
+			mi, mj := monikerOrAddress(monikers, validators[i]), monikerOrAddress(monikers, validators[j])
+			if mi != mj {
+				return mi < mj
+			}
+		}
+		return validators[i] < validators[j]
+	})
+
+	return validators
+}
+
+func monikerOrAddress(monikers map[string]string, validator string) string {
+	if m := monikers[validator]; m != "" {
+		return m
+	}
+	return validator
+}
+
+func (dr durationResult) netRewards(validator, denom string) *big.Int {
 	v := validator
-	return (&big.Int{}).Sub(dr.rewards[v], dr.fees[v])
+	return (&big.Int{}).Sub(dr.rewards[v][denom], dr.fees[v][denom])
 }
 
 func initAccountResults(accounts []string) accountResults {
@@ -34,77 +123,199 @@ func initAccountResults(accounts []string) accountResults {
 	return accountResults
 }
 
-func initDurationResult(duration time.Time) durationResult {
+func initDurationResult(duration time.Time, label string) durationResult {
 	return durationResult{
-		duration:    duration,
-		validators:  map[string]bool{},
-		delegations: map[string]*big.Int{},
-		rewards:     map[string]*big.Int{},
-		fees:        map[string]*big.Int{},
+		duration:         duration,
+		label:            label,
+		validators:       map[string]bool{},
+		delegations:      map[string]client.Coins{},
+		unbonding:        map[string]client.Coins{},
+		redelegationsIn:  map[string]client.Coins{},
+		redelegationsOut: map[string]client.Coins{},
+		rewards:          map[string]client.Coins{},
+		fees:             map[string]client.Coins{},
+		pendingRewards:   map[string]client.Coins{},
+		rewardsFiat:      fiatAmounts{},
+		feesFiat:         fiatAmounts{},
+		holdings:         initHoldings(),
 	}
 }
 
-func (ar accountResults) writeToDisk(accounts []string, path string) error {
+// outputOptions are the optional columns of the output files.
+type outputOptions struct {
+	// units adds display unit columns when not nil.
+	units map[string]client.DenomUnit
+	// currency adds fiat value columns in the currency when not empty.
+	currency string
+	// monikers orders validators by moniker instead of address when not nil.
+	monikers map[string]string
+}
 
-	f, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+// reportTable has one row per validator and denom.
+func (ar accountResults) reportTable(accounts []string, opts outputOptions) Table {
+
+	units := opts.units
 
-	cw := csv.NewWriter(f)
-	headers := []string{"account", "date", "validator", "delegation", "gross_rewards", "fees", "net_rewards"}
-	if err := cw.Write(headers); err != nil {
-		return err
+	amountHeaders := []string{
+		"delegation", "unbonding", "redelegating_in", "redelegating_out",
+		"gross_rewards", "fees", "net_rewards", "pending_rewards",
+	}
+	headers := append([]string{"account", "date", "validator", "denom"}, amountHeaders...)
+	if units != nil {
+		headers = append(headers, displayHeaders(amountHeaders)...)
 	}
-	defer cw.Flush()
+	if opts.currency != "" {
+		for _, h := range []string{"gross_rewards", "fees", "net_rewards"} {
+			headers = append(headers, h+"_"+strings.ToLower(opts.currency))
+		}
+	}
+	t := Table{Headers: headers}
 
 	// Write all the data and skip nil big ints.
 	for _, acc := range accounts {
 		durationResults := ar[acc]
 		for _, result := range durationResults {
-			date := result.duration.Format("2006-01")
+			date := result.label
 
 			// If this account isn't staking anything then write zeroes and move on.
 			if len(result.validators) == 0 {
-				cw.Write([]string{acc, date, "", "0", "0", "0", "0"})
+				values := []string{acc, date, "", "", "0", "0", "0", "0", "0", "0", "0", "0"}
+				if units != nil {
+					values = append(values, "", "0", "0", "0", "0", "0", "0", "0", "0")
+				}
+				if opts.currency != "" {
+					zero := new(big.Rat)
+					values = append(values, fiatString(zero), fiatString(zero), fiatString(zero))
+				}
+				t.Rows = append(t.Rows, values)
 				continue
 			}
 
-			for v := range result.validators {
+			// One row per denom the validator has any amount in.
+			for _, v := range result.sortedValidators(opts.monikers) {
+				for _, denom := range result.validatorDenoms(v) {
 
-				values := []string{acc, date, v}
-				var delegation, rewards, fees, netRewards string
+					rewards, fees := result.rewards[v][denom], result.fees[v][denom]
 
-				if value := result.delegations[v]; value != nil {
-					delegation = value.String()
-				}
-				if value := result.rewards[v]; value != nil {
-					rewards = value.String()
-				}
-				if value := result.fees[v]; value != nil {
-					fees = value.String()
+					// The latter two cases should never happen but it felt more complete
+					// to include them.
+					var netRewards *big.Int
+					if rewards != nil && fees != nil {
+						netRewards = result.netRewards(v, denom)
+					} else if rewards != nil {
+						netRewards = rewards
+					} else if fees != nil {
+						netRewards = fees
+					}
+
+					amounts := []*big.Int{
+						result.delegations[v][denom],
+						result.unbonding[v][denom],
+						result.redelegationsIn[v][denom],
+						result.redelegationsOut[v][denom],
+						rewards,
+						fees,
+						netRewards,
+						result.pendingRewards[v][denom],
+					}
+
+					values := []string{acc, date, v, denom}
+					for _, amount := range amounts {
+						values = append(values, optionalAmountString(amount, 0))
+					}
+					if units != nil {
+						unit := displayUnit(units, denom)
+						values = append(values, unit.Display)
+						for _, amount := range amounts {
+							values = append(values, optionalAmountString(amount, unit.Exponent))
+						}
+					}
+					if opts.currency != "" {
+						rewardsFiat, feesFiat := result.rewardsFiat[v][denom], result.feesFiat[v][denom]
+						var netFiat *big.Rat
+						if rewardsFiat != nil {
+							netFiat = new(big.Rat).Set(rewardsFiat)
+							if feesFiat != nil {
+								netFiat = netFiat.Sub(netFiat, feesFiat)
+							}
+						}
+						values = append(values, fiatString(rewardsFiat), fiatString(feesFiat), fiatString(netFiat))
+					}
+
+					t.Rows = append(t.Rows, values)
 				}
+			}
+		}
+	}
+
+	return t
+}
+
+// summaryTable has the total holdings of every account at the end of each period, one row per denom.
+func (ar accountResults) summaryTable(accounts []string, opts outputOptions) Table {
 
-				// The latter two cases should never happen but it felt more complete
-				// to include them.
-				if rewards != "" && fees != "" {
-					diff := big.NewInt(0)
-					diff = diff.Sub(result.rewards[v], result.fees[v])
-					netRewards = diff.String()
-				} else if rewards != "" {
-					netRewards = rewards
-				} else if fees != "" {
-					netRewards = fees
+	units := opts.units
+
+	amountHeaders := []string{"liquid", "delegated", "unbonding", "unclaimed_rewards", "total"}
+	headers := append([]string{"account", "date", "denom"}, amountHeaders...)
+	if units != nil {
+		headers = append(headers, displayHeaders(amountHeaders)...)
+	}
+	t := Table{Headers: headers}
+
+	for _, acc := range accounts {
+		for _, result := range ar[acc] {
+			h := result.holdings
+			for _, denom := range h.denoms() {
+				amounts := []*big.Int{
+					h.liquid[denom],
+					h.delegated[denom],
+					h.unbonding[denom],
+					h.unclaimedRewards[denom],
+					h.total(denom),
 				}
 
-				values = append(values, delegation, rewards, fees, netRewards)
-				if err = cw.Write(values); err != nil {
-					return err
+				values := []string{acc, result.label, denom}
+				for _, amount := range amounts {
+					values = append(values, amountString(amount, 0))
 				}
+				if units != nil {
+					unit := displayUnit(units, denom)
+					values = append(values, unit.Display)
+					for _, amount := range amounts {
+						values = append(values, amountString(amount, unit.Exponent))
+					}
+				}
+
+				t.Rows = append(t.Rows, values)
 			}
 		}
 	}
 
-	return nil
+	return t
+}
+
+// displayHeaders returns the headers of the display unit columns written after the base unit ones.
+func displayHeaders(amountHeaders []string) []string {
+	headers := []string{"display_denom"}
+	for _, h := range amountHeaders {
+		headers = append(headers, h+"_display")
+	}
+	return headers
+}
+
+// amountString formats the amount in a unit 10^exponent times the base unit, writing nil as zero.
+func amountString(amount *big.Int, exponent uint32) string {
+	if amount == nil {
+		return "0"
+	}
+	return displayAmount(amount, exponent)
+}
+
+// optionalAmountString is like amountString but leaves nil amounts empty.
+func optionalAmountString(amount *big.Int, exponent uint32) string {
+	if amount == nil {
+		return ""
+	}
+	return displayAmount(amount, exponent)
 }
@@ -4,20 +4,25 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/figment-networks/cosmos-extract/client"
+	"github.com/figment-networks/cosmos-extract/pricing"
 	"github.com/figment-networks/indexing-engine/structs"
 	"go.uber.org/zap"
 )
 
-const (
-	network string = "cosmos"
-	chainID string = "cosmoshub-4"
-)
-
 type Runner interface {
 	Run(ctx context.Context, config *Config) error
+	// Heights resolves the periods of the run to the last height of each without fetching anything else.
+	Heights(ctx context.Context, config *Config) ([]PeriodHeight, error)
+	// Rewards returns every reward the account was credited between the config's start and end times.
+	Rewards(ctx context.Context, config *Config, account string) ([]client.RewardEntry, error)
+	// LastHeightBefore returns the last height before the time and the segment of the chain's history
+	// serving it, routed the way the runner routes periods.
+	LastHeightBefore(ctx context.Context, config *Config, before time.Time) (Segment, uint64, error)
 }
 
 func NewRunner(logger *zap.Logger, cosmosClient client.Client) Runner {
@@ -25,14 +30,54 @@ func NewRunner(logger *zap.Logger, cosmosClient client.Client) Runner {
 }
 
 type Config struct {
+	Network   string
+	ChainID   string
 	StartTime time.Time
 	EndTime   time.Time
-	// This will always be by month unless we need it otherwise.
-	// GroupBy time.Duration
-	Accounts   []string
-	OutputPath string
+	// Granularity is the length of the periods the report is grouped by. Boundaries are the period
+	// start times used with GranularityCustom, the last of which ends the last period.
+	Granularity string
+	Boundaries  []time.Time
+	// Location is the time zone period boundaries fall on, UTC when nil.
+	Location *time.Location
+	// PartialPeriods clips the first and last periods to StartTime and EndTime instead of reporting
+	// them whole. The last delegation snapshot is then taken at EndTime.
+	PartialPeriods bool
+	Accounts       []string
+	OutputPath     string
+	// Format is one of the Format constants. When empty it is picked from OutputPath's extension.
+	Format string
+	// ValidatorOrder orders each period's rows by validator address, the default, or by moniker.
+	ValidatorOrder string
+	// Workers is the number of (period, account) pairs fetched concurrently.
+	Workers int
+	// Checkpoint, when set, records progress as the run goes. With Resume, work already recorded for
+	// the same run is skipped; without it any previous progress is discarded.
+	Checkpoint *Checkpoint
+	Resume     bool
+	// Segments split the chain's history by chain ID and archive. When empty the runner's client is
+	// used for the whole range under ChainID.
+	Segments []Segment
+	// HeightSource and RewardsSource are the sources the clients were configured with. They only tell
+	// checkpointed results apart; empty sources are the search service.
+	HeightSource  string
+	RewardsSource string
+	// DisplayUnits also writes every amount in its denom's display unit.
+	DisplayUnits bool
+	DenomUnits   map[string]client.DenomUnit
+	// Sink, when set, also stores the results in a SQL database.
+	Sink *SQLSink
+	// Prices, when set, adds the fiat value of the rewards in Currency.
+	Prices   pricing.PriceSource
+	Currency string
 }
 
+// Supported values for Config.ValidatorOrder. An empty order behaves like ValidatorOrderAddress.
+const (
+	ValidatorOrderAddress = "address"
+	ValidatorOrderMoniker = "moniker"
+)
+
 type runner struct {
 	logger *zap.Logger
 	client client.Client
@@ -44,72 +89,287 @@ func (r *runner) Run(ctx context.Context, cfg *Config) error {
 		return errors.New("no config provided")
 	}
 
+	if cfg.Prices != nil && cfg.Currency == "" {
+		return errors.New("prices need a currency to name the fiat columns after")
+	}
+
+	writer, err := NewWriter(cfg.Format, cfg.OutputPath)
+	if err != nil {
+		return err
+	}
+
 	startTime := time.Now()
 	r.logger.Info("Starting report run...")
 
-	// Periods are built -- it is a chronologically ordered slice of month start and end
-	// times and the the last height for each month. This data allows us to efficiently
+	// Periods are built -- it is a chronologically ordered slice of period start and end
+	// times and the the last height for each period. This data allows us to efficiently
 	// query account delegation balances and rewards.
-	periods, err := r.buildOrderedPeriods(ctx, cfg.StartTime, cfg.EndTime)
+	if cfg.Checkpoint != nil && !cfg.Resume {
+		if err := cfg.Checkpoint.reset(cfg); err != nil {
+			return fmt.Errorf("could not reset checkpoint: %w", err)
+		}
+	}
+
+	segments, err := r.segments(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	periods, err := r.buildOrderedPeriods(ctx, cfg, segments)
+	if err != nil {
+		return err
+	}
+
+	units, err := r.denomUnits(ctx, cfg)
 	if err != nil {
 		return err
 	}
 
+	var monikers map[string]string
+	if cfg.ValidatorOrder == ValidatorOrderMoniker {
+		if monikers, err = validatorMonikers(ctx, segments); err != nil {
+			return fmt.Errorf("could not get validator monikers: %w", err)
+		}
+	}
+
 	accounts := cfg.Accounts
 	results := initAccountResults(accounts)
 
-	// For each period, get data for each account.
-	for _, period := range periods {
-		for _, acc := range accounts {
+	// Every (period, account) pair is independent, so they are fanned out to the workers. Each result
+	// has its own slot so the output order doesn't depend on which worker finishes first.
+	slots := make([][]durationResult, len(accounts))
+	for i := range slots {
+		slots[i] = make([]durationResult, len(periods))
+	}
 
-			durationResult := initDurationResult(period.startTime)
+	jobs := make(chan job)
+	workCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-			// Step 1: Get the delegation balances by validator.
-			heightAccount := structs.HeightAccount{
-				Height:  period.endHeight,
-				Account: acc,
-				Network: network,
-				ChainID: chainID,
-			}
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var runErr error
 
-			r.logger.Info("Getting account delegations", zap.String("account", acc), zap.Time("period", period.startTime))
-			delegationsResp, err := r.client.GetAccountDelegations(ctx, heightAccount)
-			if err != nil {
-				return fmt.Errorf("could not get account delegations for %+v: %w", heightAccount, err)
-			}
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
 
-			for _, d := range delegationsResp.Delegations {
-				durationResult.validators[string(d.Validator)] = true
-				durationResult.delegations[string(d.Validator)] = d.Balance.Numeric
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				result, err := r.runAccountPeriod(workCtx, cfg, segments, units, periods[j.period], accounts[j.account])
+				if err == nil && cfg.Checkpoint != nil {
+					if err = cfg.Checkpoint.saveResult(cfg, accounts[j.account], j.period, result); err != nil {
+						err = fmt.Errorf("could not checkpoint result: %w", err)
+					}
+				}
+				if err != nil {
+					errOnce.Do(func() {
+						runErr = err
+						cancel()
+					})
+					continue
+				}
+				slots[j.account][j.period] = result
 			}
+		}()
+	}
 
-			// Step 2: Get rewards earned by validator.
-			rewReq := client.RewardsReq{
-				Network:   network,
-				ChainID:   chainID,
-				Account:   acc,
-				StartTime: period.startTime,
-				EndTime:   period.nextStartTime,
-			}
+	var resumed int
 
-			r.logger.Info("Getting account rewards", zap.String("account", acc), zap.Time("period", period.startTime))
-			rewSum, feeSum, err := r.client.GetRewardsAndFeesSum(ctx, rewReq)
-			if err != nil {
-				return fmt.Errorf("could not get rewards for %+v: %w", rewReq, err)
+Loop:
+	for p := range periods {
+		for a := range accounts {
+			if cfg.Checkpoint != nil && cfg.Resume {
+				result, ok, err := cfg.Checkpoint.loadResult(cfg, accounts[a], p)
+				if err != nil {
+					errOnce.Do(func() {
+						runErr = fmt.Errorf("could not load checkpoint: %w", err)
+						cancel()
+					})
+					break Loop
+				}
+				if ok {
+					slots[a][p] = result
+					resumed++
+					continue
+				}
 			}
 
-			// Not possible to have fees without rewards, so just check rewards.
-			durationResult.rewards = rewSum
-			for v := range rewSum {
-				durationResult.validators[v] = true
+			select {
+			case jobs <- job{period: p, account: a}:
+			case <-workCtx.Done():
+				break Loop
 			}
-			durationResult.fees = feeSum
-
-			results[acc] = append(results[acc], durationResult)
 		}
 	}
+	close(jobs)
+	wg.Wait()
+
+	if runErr != nil {
+		return runErr
+	}
+	if resumed > 0 {
+		r.logger.Info("Resumed results from checkpoint", zap.Int("count", resumed))
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for i, acc := range accounts {
+		results[acc] = slots[i]
+	}
 
 	r.logger.Info("REPORT RUN COMPLETE in " + time.Since(startTime).String())
 
-	return results.writeToDisk(cfg.Accounts, cfg.OutputPath)
+	opts := outputOptions{currency: currency(cfg), monikers: monikers}
+	if cfg.DisplayUnits {
+		opts.units = units
+	}
+
+	if cfg.Checkpoint != nil {
+		if err := cfg.Checkpoint.complete(cfg, opts); err != nil {
+			return fmt.Errorf("could not checkpoint run: %w", err)
+		}
+	}
+
+	// Accounts are sorted like validators so the output doesn't depend on the order they were configured in.
+	sortedAccounts := append([]string{}, cfg.Accounts...)
+	sort.Strings(sortedAccounts)
+
+	files, err := writer.Write(cfg.OutputPath, results.reportTable(sortedAccounts, opts), results.summaryTable(sortedAccounts, opts))
+	if err != nil {
+		return err
+	}
+
+	if err := writeChecksums(checksumPath(cfg.OutputPath), files); err != nil {
+		return fmt.Errorf("could not write checksums: %w", err)
+	}
+
+	if cfg.Sink != nil {
+		if err := cfg.Sink.write(ctx, cfg, periods, results); err != nil {
+			return fmt.Errorf("could not write results to sql sink: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type job struct {
+	period  int
+	account int
+}
+
+// runAccountPeriod gets the delegations and rewards of a single account for a single period.
+func (r *runner) runAccountPeriod(ctx context.Context, cfg *Config, segments []Segment, units map[string]client.DenomUnit, period period, acc string) (durationResult, error) {
+
+	durationResult := initDurationResult(period.startTime, period.label)
+
+	// Step 1: Get the delegation balances by validator.
+	heightAccount := structs.HeightAccount{
+		Height:  period.endHeight,
+		Account: acc,
+		Network: cfg.Network,
+		ChainID: period.segment.ChainID,
+	}
+
+	r.logger.Info("Getting account delegations", zap.String("account", acc), zap.Time("period", period.startTime))
+	delegationsResp, err := period.segment.Client.GetAccountDelegations(ctx, heightAccount)
+	if err != nil {
+		return durationResult, fmt.Errorf("could not get account delegations for %+v: %w", heightAccount, err)
+	}
+
+	for _, d := range delegationsResp.Delegations {
+		durationResult.validators[string(d.Validator)] = true
+		addAmount(durationResult.delegations, string(d.Validator), d.Balance.Currency, d.Balance.Numeric)
+		durationResult.holdings.delegated.Add(d.Balance.Currency, d.Balance.Numeric)
+	}
+
+	// Tokens that are unbonding or being redelegated at the end of the period are still the account's.
+	r.logger.Info("Getting account unbonding delegations", zap.String("account", acc), zap.Time("period", period.startTime))
+	unbondings, err := period.segment.Client.GetUnbondingDelegations(ctx, heightAccount)
+	if err != nil {
+		return durationResult, fmt.Errorf("could not get unbonding delegations for %+v: %w", heightAccount, err)
+	}
+
+	for _, u := range unbondings {
+		durationResult.validators[u.Validator] = true
+		addAmount(durationResult.unbonding, u.Validator, u.Denom, u.Balance)
+		durationResult.holdings.unbonding.Add(u.Denom, u.Balance)
+	}
+
+	r.logger.Info("Getting account redelegations", zap.String("account", acc), zap.Time("period", period.startTime))
+	redelegations, err := period.segment.Client.GetRedelegations(ctx, heightAccount)
+	if err != nil {
+		return durationResult, fmt.Errorf("could not get redelegations for %+v: %w", heightAccount, err)
+	}
+
+	for _, rd := range redelegations {
+		durationResult.validators[rd.SrcValidator] = true
+		durationResult.validators[rd.DstValidator] = true
+		addAmount(durationResult.redelegationsOut, rd.SrcValidator, rd.Denom, rd.Balance)
+		addAmount(durationResult.redelegationsIn, rd.DstValidator, rd.Denom, rd.Balance)
+	}
+
+	// Step 2: Get the liquid balances and unclaimed rewards that complete the account's holdings.
+	r.logger.Info("Getting account balances", zap.String("account", acc), zap.Time("period", period.startTime))
+	durationResult.holdings.liquid, err = period.segment.Client.GetAccountBalances(ctx, heightAccount)
+	if err != nil {
+		return durationResult, fmt.Errorf("could not get balances for %+v: %w", heightAccount, err)
+	}
+
+	// Unclaimed rewards are the pending rewards of every validator, each truncated as when withdrawn.
+	r.logger.Info("Getting account pending rewards", zap.String("account", acc), zap.Time("period", period.startTime))
+	pending, err := period.segment.Client.GetDelegationRewards(ctx, heightAccount)
+	if err != nil {
+		return durationResult, fmt.Errorf("could not get pending rewards for %+v: %w", heightAccount, err)
+	}
+
+	durationResult.pendingRewards = pending
+	for v, coins := range pending {
+		durationResult.validators[v] = true
+		durationResult.holdings.unclaimedRewards.AddCoins(coins)
+	}
+
+	// Step 3: Get rewards earned by validator.
+	rewReq := client.RewardsReq{
+		Network:   cfg.Network,
+		ChainID:   cfg.ChainID,
+		Account:   acc,
+		StartTime: period.startTime,
+		EndTime:   period.nextStartTime,
+	}
+
+	r.logger.Info("Getting account rewards", zap.String("account", acc), zap.Time("period", period.startTime))
+	var rewSum, feeSum map[string]client.Coins
+	if cfg.Prices == nil {
+		rewSum, feeSum, err = rewardsAndFeesSum(ctx, segments, rewReq)
+		if err != nil {
+			return durationResult, fmt.Errorf("could not get rewards for %+v: %w", rewReq, err)
+		}
+	} else {
+		// Fiat values need the rewards as credited to price each at its own day.
+		entries, err := rewardEntries(ctx, segments, rewReq)
+		if err != nil {
+			return durationResult, fmt.Errorf("could not get rewards for %+v: %w", rewReq, err)
+		}
+
+		rewSum, feeSum = client.SumRewards(entries)
+		durationResult.rewardsFiat, durationResult.feesFiat, err = valueRewards(ctx, cfg.Prices, units, entries)
+		if err != nil {
+			return durationResult, fmt.Errorf("could not value rewards for %+v: %w", rewReq, err)
+		}
+	}
+
+	// Not possible to have fees without rewards, so just check rewards.
+	durationResult.rewards = rewSum
+	for v := range rewSum {
+		durationResult.validators[v] = true
+	}
+	durationResult.fees = feeSum
+
+	return durationResult, nil
 }
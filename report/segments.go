@@ -0,0 +1,290 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/figment-networks/cosmos-extract/client"
+)
+
+// Segment is a part of a chain's history served by its own chain ID and archive, such as the blocks of
+// cosmoshub-3 before the upgrade to cosmoshub-4. Bounds may be given as times or heights; zero values
+// leave that side unbounded.
+type Segment struct {
+	ChainID     string
+	StartTime   time.Time
+	EndTime     time.Time
+	StartHeight uint64
+	EndHeight   uint64
+	Client      client.Client
+}
+
+// segments returns the chronologically ordered segments for the run, with any height bounds resolved
+// to times. Without configured segments the runner's own client serves the whole chain.
+func (r *runner) segments(ctx context.Context, cfg *Config) ([]Segment, error) {
+
+	if len(cfg.Segments) == 0 {
+		return []Segment{{ChainID: cfg.ChainID, Client: r.client}}, nil
+	}
+
+	resolved := make([]resolvedSegment, len(cfg.Segments))
+	for i, s := range cfg.Segments {
+		resolved[i].Segment = s
+
+		if s.StartTime.IsZero() && s.StartHeight > 0 {
+			t, err := s.Client.GetBlockTime(ctx, s.StartHeight)
+			if err != nil {
+				return nil, fmt.Errorf("could not get start time of %s: %w", s.ChainID, err)
+			}
+			resolved[i].StartTime = t
+			resolved[i].startFromHeight = true
+		}
+
+		if s.EndTime.IsZero() && s.EndHeight > 0 {
+			t, err := s.Client.GetBlockTime(ctx, s.EndHeight)
+			if err != nil {
+				return nil, fmt.Errorf("could not get end time of %s: %w", s.ChainID, err)
+			}
+			// The last block is still part of the segment.
+			resolved[i].EndTime = t.Add(time.Nanosecond)
+			resolved[i].endFromHeight = true
+		}
+	}
+
+	sort.SliceStable(resolved, func(i, j int) bool {
+		return resolved[i].StartTime.Before(resolved[j].StartTime)
+	})
+
+	segments := make([]Segment, len(resolved))
+	for i, s := range resolved {
+		if !s.EndTime.IsZero() && !s.StartTime.Before(s.EndTime) {
+			return nil, fmt.Errorf("segment %s ends before it starts", s.ChainID)
+		}
+		if i > 0 {
+			if err := checkSegmentBoundary(resolved[i-1], s); err != nil {
+				return nil, err
+			}
+		}
+		segments[i] = s.Segment
+	}
+
+	return segments, nil
+}
+
+// resolvedSegment records which of a segment's times were resolved from its heights.
+type resolvedSegment struct {
+	Segment
+	startFromHeight bool
+	endFromHeight   bool
+}
+
+// checkSegmentBoundary checks that a segment takes over where the previous one ends, so every time of
+// the run is routed to a single archive.
+func checkSegmentBoundary(prev, next resolvedSegment) error {
+
+	if next.StartTime.IsZero() {
+		return fmt.Errorf("segment %s follows %s but has no start", next.ChainID, prev.ChainID)
+	}
+	if prev.EndTime.IsZero() {
+		return nil
+	}
+
+	if prev.EndTime.After(next.StartTime) {
+		return fmt.Errorf("segments %s and %s overlap between %s and %s", prev.ChainID, next.ChainID, next.StartTime, prev.EndTime)
+	}
+
+	// A chain is halted between its last block and the next chain's first one, so times resolved from
+	// heights may leave a gap. Times given as such must meet.
+	if prev.EndTime.Before(next.StartTime) && !prev.endFromHeight && !next.startFromHeight {
+		return fmt.Errorf("no segment covers %s to %s between %s and %s", prev.EndTime, next.StartTime, prev.ChainID, next.ChainID)
+	}
+
+	return nil
+}
+
+// segmentBefore returns the segment holding the last block before the provided time, which is the latest
+// segment that started before it.
+func segmentBefore(segments []Segment, before time.Time) Segment {
+
+	seg := segments[0]
+	for _, s := range segments[1:] {
+		if !s.StartTime.Before(before) {
+			break
+		}
+		seg = s
+	}
+
+	return seg
+}
+
+// lastHeightBefore looks up the last height before the provided time on the segment that holds it.
+func lastHeightBefore(ctx context.Context, segments []Segment, network string, before time.Time) (Segment, uint64, error) {
+
+	seg := segmentBefore(segments, before)
+
+	// Past its end the segment's last block is the one before its end.
+	if !seg.EndTime.IsZero() && seg.EndTime.Before(before) {
+		before = seg.EndTime
+	}
+
+	height, err := seg.Client.GetLastHeightBefore(ctx, client.LastHeightBeforeReq{
+		Network:    network,
+		ChainID:    seg.ChainID,
+		BeforeTime: before,
+	})
+	if err != nil {
+		return seg, 0, err
+	}
+
+	// An archive may keep blocks past the upgrade height; those belong to the next segment.
+	if seg.EndHeight > 0 && height > seg.EndHeight {
+		height = seg.EndHeight
+	}
+
+	return seg, height, nil
+}
+
+// rewardsAndFeesSum sums the rewards and fees of the request over every segment it overlaps, querying each
+// segment for its part of the time range only.
+func rewardsAndFeesSum(ctx context.Context, segments []Segment, req client.RewardsReq) (rewards map[string]client.Coins, fees map[string]client.Coins, err error) {
+
+	rewards = map[string]client.Coins{}
+	fees = map[string]client.Coins{}
+
+	err = forEachSegment(segments, req, func(s Segment, segReq client.RewardsReq) error {
+		segRewards, segFees, err := s.Client.GetRewardsAndFeesSum(ctx, segReq)
+		if err != nil {
+			return err
+		}
+
+		addAmounts(rewards, segRewards)
+		addAmounts(fees, segFees)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rewards, fees, nil
+}
+
+func (r *runner) Rewards(ctx context.Context, cfg *Config, account string) ([]client.RewardEntry, error) {
+
+	if cfg == nil {
+		return nil, errors.New("no config provided")
+	}
+
+	segments, err := r.segments(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return rewardEntries(ctx, segments, client.RewardsReq{
+		Network:   cfg.Network,
+		ChainID:   cfg.ChainID,
+		Account:   account,
+		StartTime: cfg.StartTime,
+		EndTime:   cfg.EndTime,
+	})
+}
+
+func (r *runner) LastHeightBefore(ctx context.Context, cfg *Config, before time.Time) (Segment, uint64, error) {
+
+	if cfg == nil {
+		return Segment{}, 0, errors.New("no config provided")
+	}
+
+	segments, err := r.segments(ctx, cfg)
+	if err != nil {
+		return Segment{}, 0, err
+	}
+
+	return lastHeightBefore(ctx, segments, cfg.Network, before)
+}
+
+// rewardEntries is like rewardsAndFeesSum but returns the rewards as credited instead of summed.
+func rewardEntries(ctx context.Context, segments []Segment, req client.RewardsReq) (entries []client.RewardEntry, err error) {
+
+	err = forEachSegment(segments, req, func(s Segment, segReq client.RewardsReq) error {
+		segEntries, err := s.Client.GetRewards(ctx, segReq)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, segEntries...)
+		return nil
+	})
+
+	return entries, err
+}
+
+// forEachSegment calls fn with every segment the request overlaps and its part of the request.
+func forEachSegment(segments []Segment, req client.RewardsReq, fn func(s Segment, segReq client.RewardsReq) error) error {
+
+	for i, s := range segments {
+		start := req.StartTime
+		if s.StartTime.After(start) {
+			start = s.StartTime
+		}
+
+		// A segment ends at its own end, or where the next one takes over when it has none.
+		end := req.EndTime
+		if !s.EndTime.IsZero() && s.EndTime.Before(end) {
+			end = s.EndTime
+		}
+		if i < len(segments)-1 && segments[i+1].StartTime.Before(end) {
+			end = segments[i+1].StartTime
+		}
+
+		if !start.Before(end) {
+			continue
+		}
+
+		segReq := req
+		segReq.ChainID = s.ChainID
+		segReq.StartTime = start
+		segReq.EndTime = end
+
+		if err := fn(s, segReq); err != nil {
+			return fmt.Errorf("%s: %w", s.ChainID, err)
+		}
+	}
+
+	return nil
+}
+
+// validatorMonikers returns the latest moniker of every validator of the segments.
+func validatorMonikers(ctx context.Context, segments []Segment) (map[string]string, error) {
+
+	monikers := map[string]string{}
+	for _, s := range segments {
+		validators, err := s.Client.GetValidators(ctx, s.EndHeight)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.ChainID, err)
+		}
+		for _, v := range validators {
+			monikers[v.OperatorAddress] = v.Moniker
+		}
+	}
+
+	return monikers, nil
+}
+
+func addAmounts(dst, src map[string]client.Coins) {
+	for k, coins := range src {
+		for denom, amount := range coins {
+			addAmount(dst, k, denom, amount)
+		}
+	}
+}
+
+func addAmount(dst map[string]client.Coins, key, denom string, amount *big.Int) {
+	if _, ok := dst[key]; !ok {
+		dst[key] = client.Coins{}
+	}
+	dst[key].Add(denom, amount)
+}
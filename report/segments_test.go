@@ -0,0 +1,83 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/figment-networks/cosmos-extract/client"
+	"go.uber.org/zap"
+)
+
+var testGenesis = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeArchive serves a block a minute from first to last, the times of each derived from its height.
+type fakeArchive struct {
+	client.Client
+	first, last uint64
+}
+
+func (a fakeArchive) GetBlockTime(ctx context.Context, height uint64) (time.Time, error) {
+	if height < a.first || height > a.last {
+		return time.Time{}, fmt.Errorf("height %d is not available", height)
+	}
+	return testGenesis.Add(time.Duration(height) * time.Minute), nil
+}
+
+func (a fakeArchive) GetLastHeightBefore(ctx context.Context, req client.LastHeightBeforeReq) (uint64, error) {
+	// The last block strictly before the time.
+	h := uint64((req.BeforeTime.Sub(testGenesis)+time.Minute-1)/time.Minute) - 1
+	if h < a.first {
+		return 0, fmt.Errorf("no block before %s", req.BeforeTime)
+	}
+	if h > a.last {
+		h = a.last
+	}
+	return h, nil
+}
+
+func TestLastHeightBeforeHeightBoundedHistory(t *testing.T) {
+
+	ctx := context.Background()
+
+	// cosmoshub-3 halts at height 100, and cosmoshub-4 starts at height 110 after a pause. The old
+	// archive also kept blocks past the upgrade height.
+	cfg := &Config{
+		Network: "cosmos",
+		ChainID: "cosmoshub-4",
+		Segments: []Segment{
+			{ChainID: "cosmoshub-3", EndHeight: 100, Client: fakeArchive{first: 1, last: 105}},
+			{ChainID: "cosmoshub-4", StartHeight: 110, Client: fakeArchive{first: 110, last: 300}},
+		},
+	}
+	r := NewRunner(zap.NewNop(), cfg.Segments[1].Client)
+
+	blockTime := func(height uint64) time.Time {
+		return testGenesis.Add(time.Duration(height) * time.Minute)
+	}
+
+	tests := []struct {
+		name        string
+		before      time.Time
+		wantChainID string
+		wantHeight  uint64
+	}{
+		{"before the upgrade", blockTime(50).Add(time.Second), "cosmoshub-3", 50},
+		{"at the halt", blockTime(100).Add(time.Nanosecond), "cosmoshub-3", 100},
+		{"during the pause", blockTime(105), "cosmoshub-3", 100},
+		{"after the upgrade", blockTime(200).Add(time.Second), "cosmoshub-4", 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seg, height, err := r.LastHeightBefore(ctx, cfg, tt.before)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seg.ChainID != tt.wantChainID || height != tt.wantHeight {
+				t.Errorf("got %s at height %d, want %s at height %d", seg.ChainID, height, tt.wantChainID, tt.wantHeight)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"           // postgres driver
+	_ "github.com/mattn/go-sqlite3" // sqlite3 driver
+)
+
+// Supported SQL drivers. sqlite3 needs a cgo build.
+const (
+	SQLDriverSQLite   = "sqlite3"
+	SQLDriverPostgres = "postgres"
+)
+
+// sqlMigrations create the sink's schema, applied in order.
+var sqlMigrations = []string{
+	`CREATE TABLE runs (
+		id          TEXT PRIMARY KEY,
+		network     TEXT NOT NULL,
+		chain_id    TEXT NOT NULL,
+		start_time  {{time}} NOT NULL,
+		end_time    {{time}} NOT NULL,
+		granularity TEXT NOT NULL,
+		currency    TEXT NOT NULL,
+		updated_at  {{time}} NOT NULL
+	)`,
+	`CREATE TABLE periods (
+		run_id      TEXT NOT NULL REFERENCES runs (id),
+		start_time  {{time}} NOT NULL,
+		end_time    {{time}} NOT NULL,
+		label       TEXT NOT NULL,
+		chain_id    TEXT NOT NULL,
+		end_height  BIGINT NOT NULL,
+		PRIMARY KEY (run_id, start_time)
+	)`,
+	`CREATE TABLE accounts (
+		run_id  TEXT NOT NULL REFERENCES runs (id),
+		account TEXT NOT NULL,
+		PRIMARY KEY (run_id, account)
+	)`,
+	`CREATE TABLE validator_rows (
+		run_id           TEXT NOT NULL REFERENCES runs (id),
+		account          TEXT NOT NULL,
+		period_start     {{time}} NOT NULL,
+		validator        TEXT NOT NULL,
+		denom            TEXT NOT NULL,
+		delegation       {{amount}},
+		unbonding        {{amount}},
+		redelegating_in  {{amount}},
+		redelegating_out {{amount}},
+		gross_rewards    {{amount}},
+		fees             {{amount}},
+		net_rewards      {{amount}},
+		pending_rewards  {{amount}},
+		gross_rewards_fiat {{amount}},
+		fees_fiat        {{amount}},
+		PRIMARY KEY (run_id, account, period_start, validator, denom)
+	)`,
+}
+
+// SQLSink stores report results in a SQL database, upserting the rows of runs with the same config.
+type SQLSink struct {
+	db     *sql.DB
+	driver string
+}
+
+// OpenSQLSink connects to the database and brings its schema up to date.
+func OpenSQLSink(ctx context.Context, driver, dsn string) (*SQLSink, error) {
+
+	switch driver {
+	case SQLDriverSQLite, SQLDriverPostgres:
+	default:
+		return nil, fmt.Errorf("unknown sql driver %q", driver)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("could not open sql sink: %w", err)
+	}
+
+	s := &SQLSink{db: db, driver: driver}
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not migrate sql sink: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *SQLSink) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLSink) migrate(ctx context.Context) error {
+
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+
+	var version int
+	err := s.db.QueryRowContext(ctx, `SELECT version FROM schema_version`).Scan(&version)
+	if err == sql.ErrNoRows {
+		if _, err := s.db.ExecContext(ctx, `INSERT INTO schema_version (version) VALUES (0)`); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	for ; version < len(sqlMigrations); version++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, s.schema(sqlMigrations[version])); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, s.rebind(`UPDATE schema_version SET version = ?`), version+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// schema fills in the column types that differ between databases.
+func (s *SQLSink) schema(stmt string) string {
+	timeType, amountType := "TIMESTAMPTZ", "NUMERIC"
+	if s.driver == SQLDriverSQLite {
+		timeType, amountType = "TIMESTAMP", "TEXT"
+	}
+	return strings.NewReplacer("{{time}}", timeType, "{{amount}}", amountType).Replace(stmt)
+}
+
+// rebind replaces ? placeholders with the numbered ones Postgres expects.
+func (s *SQLSink) rebind(query string) string {
+
+	if s.driver != SQLDriverPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// write upserts the run, its periods, accounts and a row per (account, period, validator, denom).
+func (s *SQLSink) write(ctx context.Context, cfg *Config, periods []period, results accountResults) error {
+
+	// runKey is the hex SHA-256 of everything that determines the run's results.
+	runID := string(runKey(cfg))
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, s.rebind(`
+		INSERT INTO runs (id, network, chain_id, start_time, end_time, granularity, currency, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET updated_at = excluded.updated_at`),
+		runID, cfg.Network, cfg.ChainID, cfg.StartTime.UTC(), cfg.EndTime.UTC(), cfg.Granularity, currency(cfg), time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("could not write run: %w", err)
+	}
+
+	for _, p := range periods {
+		_, err = tx.ExecContext(ctx, s.rebind(`
+			INSERT INTO periods (run_id, start_time, end_time, label, chain_id, end_height)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (run_id, start_time) DO UPDATE SET
+				end_time = excluded.end_time, label = excluded.label,
+				chain_id = excluded.chain_id, end_height = excluded.end_height`),
+			runID, p.startTime.UTC(), p.nextStartTime.UTC(), p.label, p.segment.ChainID, int64(p.endHeight),
+		)
+		if err != nil {
+			return fmt.Errorf("could not write period %s: %w", p.label, err)
+		}
+	}
+
+	rowStmt, err := tx.PrepareContext(ctx, s.rebind(`
+		INSERT INTO validator_rows (
+			run_id, account, period_start, validator, denom, delegation, unbonding, redelegating_in,
+			redelegating_out, gross_rewards, fees, net_rewards, pending_rewards, gross_rewards_fiat, fees_fiat
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (run_id, account, period_start, validator, denom) DO UPDATE SET
+			delegation = excluded.delegation, unbonding = excluded.unbonding,
+			redelegating_in = excluded.redelegating_in, redelegating_out = excluded.redelegating_out,
+			gross_rewards = excluded.gross_rewards, fees = excluded.fees, net_rewards = excluded.net_rewards,
+			pending_rewards = excluded.pending_rewards, gross_rewards_fiat = excluded.gross_rewards_fiat,
+			fees_fiat = excluded.fees_fiat`))
+	if err != nil {
+		return err
+	}
+	defer rowStmt.Close()
+
+	for _, acc := range cfg.Accounts {
+		_, err = tx.ExecContext(ctx, s.rebind(`
+			INSERT INTO accounts (run_id, account) VALUES (?, ?)
+			ON CONFLICT (run_id, account) DO NOTHING`),
+			runID, acc,
+		)
+		if err != nil {
+			return fmt.Errorf("could not write account %s: %w", acc, err)
+		}
+
+		for _, result := range results[acc] {
+			for _, v := range result.sortedValidators(nil) {
+				for _, denom := range result.validatorDenoms(v) {
+					_, err = rowStmt.ExecContext(ctx,
+						runID, acc, result.duration.UTC(), v, denom,
+						sqlAmount(result.delegations[v][denom]),
+						sqlAmount(result.unbonding[v][denom]),
+						sqlAmount(result.redelegationsIn[v][denom]),
+						sqlAmount(result.redelegationsOut[v][denom]),
+						sqlAmount(result.rewards[v][denom]),
+						sqlAmount(result.fees[v][denom]),
+						sqlNetRewards(result, v, denom),
+						sqlAmount(result.pendingRewards[v][denom]),
+						sqlFiat(result.rewardsFiat[v][denom]),
+						sqlFiat(result.feesFiat[v][denom]),
+					)
+					if err != nil {
+						return fmt.Errorf("could not write %s row of %s for validator %s: %w", result.label, acc, v, err)
+					}
+				}
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// sqlAmount returns the amount as a decimal string, or NULL when there is none.
+func sqlAmount(amount *big.Int) interface{} {
+	if amount == nil {
+		return nil
+	}
+	return amount.String()
+}
+
+func sqlNetRewards(result durationResult, validator, denom string) interface{} {
+	rewards, fees := result.rewards[validator][denom], result.fees[validator][denom]
+	if rewards == nil {
+		return sqlAmount(fees)
+	}
+	if fees == nil {
+		return sqlAmount(rewards)
+	}
+	return sqlAmount(result.netRewards(validator, denom))
+}
+
+// sqlFiat returns the fiat value as an exact decimal string, or NULL when there is none.
+func sqlFiat(value *big.Rat) interface{} {
+	if value == nil {
+		return nil
+	}
+	return value.FloatString(fiatPrecision)
+}
@@ -0,0 +1,153 @@
+//go:build cgo
+// +build cgo
+
+// The sqlite3 driver needs cgo; without it the driver fails to open any database.
+
+package report
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/figment-networks/cosmos-extract/client"
+)
+
+func TestSQLSinkSQLite(t *testing.T) {
+
+	ctx := context.Background()
+	dsn := filepath.Join(t.TempDir(), "sink.db")
+
+	cfg := &Config{
+		Network:     "cosmos",
+		ChainID:     "cosmoshub-4",
+		StartTime:   time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndTime:     time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+		Granularity: "monthly",
+		// An account configured twice is still written once.
+		Accounts: []string{"cosmos1abc", "cosmos1abc"},
+	}
+	periods := []period{
+		{startTime: cfg.StartTime, nextStartTime: time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC), endHeight: 100, segment: Segment{ChainID: "cosmoshub-4"}, label: "2021-01"},
+		{startTime: time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC), nextStartTime: cfg.EndTime, endHeight: 200, segment: Segment{ChainID: "cosmoshub-4"}, label: "2021-02"},
+	}
+
+	result := func(validators map[string]int64) accountResults {
+		results := initAccountResults(cfg.Accounts)
+		for _, p := range periods {
+			r := initDurationResult(p.startTime, p.label)
+			for v, amount := range validators {
+				r.validators[v] = true
+				r.delegations[v] = client.Coins{"uatom": big.NewInt(amount)}
+				r.rewards[v] = client.Coins{"uatom": big.NewInt(amount / 10)}
+			}
+			results["cosmos1abc"] = append(results["cosmos1abc"], r)
+		}
+		return results
+	}
+
+	write := func(results accountResults) {
+		t.Helper()
+		// Every write opens the sink again, which must find its schema up to date.
+		sink, err := OpenSQLSink(ctx, SQLDriverSQLite, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sink.Close()
+		if err := sink.write(ctx, cfg, periods, results); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// runs returns the IDs of the runs.
+	runs := func() []string {
+		t.Helper()
+		sink, err := OpenSQLSink(ctx, SQLDriverSQLite, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sink.Close()
+
+		res, err := sink.db.QueryContext(ctx, `SELECT id FROM runs`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Close()
+
+		var ids []string
+		for res.Next() {
+			var id string
+			if err := res.Scan(&id); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		if err := res.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+
+	rows := func(runID string) []string {
+		t.Helper()
+		sink, err := OpenSQLSink(ctx, SQLDriverSQLite, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sink.Close()
+
+		res, err := sink.db.QueryContext(ctx, `
+			SELECT account, validator, denom, delegation, gross_rewards, net_rewards FROM validator_rows
+			WHERE run_id = ? ORDER BY period_start, validator`, runID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Close()
+
+		var got []string
+		for res.Next() {
+			var acc, v, denom, delegation, rewards, net string
+			if err := res.Scan(&acc, &v, &denom, &delegation, &rewards, &net); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, acc+" "+v+" "+denom+" "+delegation+" "+rewards+" "+net)
+		}
+		if err := res.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	write(result(map[string]int64{"valA": 1000, "valB": 500}))
+	want := []string{
+		"cosmos1abc valA uatom 1000 100 100",
+		"cosmos1abc valB uatom 500 50 50",
+		"cosmos1abc valA uatom 1000 100 100",
+		"cosmos1abc valB uatom 500 50 50",
+	}
+	runID := string(runKey(cfg))
+	if ids := runs(); !reflect.DeepEqual(ids, []string{runID}) {
+		t.Fatalf("runs after the first write = %q, want %q", ids, []string{runID})
+	}
+	if got := rows(runID); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows after the first write = %q, want %q", got, want)
+	}
+
+	// Repeating the run, say after an indexer fix, updates its rows in place.
+	write(result(map[string]int64{"valA": 2000, "valB": 700}))
+	want = []string{
+		"cosmos1abc valA uatom 2000 200 200",
+		"cosmos1abc valB uatom 700 70 70",
+		"cosmos1abc valA uatom 2000 200 200",
+		"cosmos1abc valB uatom 700 70 70",
+	}
+	if ids := runs(); !reflect.DeepEqual(ids, []string{runID}) {
+		t.Fatalf("runs after the second write = %q, want %q", ids, []string{runID})
+	}
+	if got := rows(runID); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows after the second write = %q, want %q", got, want)
+	}
+}
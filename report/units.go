@@ -0,0 +1,66 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/figment-networks/cosmos-extract/client"
+)
+
+// denomUnits returns the display unit of every denom, nil when neither display units nor prices are used.
+func (r *runner) denomUnits(ctx context.Context, cfg *Config) (map[string]client.DenomUnit, error) {
+
+	if !cfg.DisplayUnits && cfg.Prices == nil {
+		return nil, nil
+	}
+
+	units, err := r.client.GetDenomsMetadata(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get denoms metadata: %w", err)
+	}
+
+	for denom, u := range cfg.DenomUnits {
+		if _, ok := units[denom]; !ok {
+			units[denom] = u
+		}
+	}
+
+	return units, nil
+}
+
+// displayUnit returns the denom's display unit, the denom itself when it has none.
+func displayUnit(units map[string]client.DenomUnit, denom string) client.DenomUnit {
+	if u, ok := units[denom]; ok {
+		return u
+	}
+	return client.DenomUnit{Display: denom}
+}
+
+// displayAmount formats a base unit amount as an exact decimal in a unit 10^exponent larger.
+func displayAmount(amount *big.Int, exponent uint32) string {
+
+	if exponent == 0 {
+		return amount.String()
+	}
+
+	abs := new(big.Int).Abs(amount)
+	digits := abs.String()
+	if pad := int(exponent) + 1 - len(digits); pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+
+	split := len(digits) - int(exponent)
+	whole, frac := digits[:split], strings.TrimRight(digits[split:], "0")
+
+	s := whole
+	if frac != "" {
+		s += "." + frac
+	}
+	if amount.Sign() < 0 {
+		s = "-" + s
+	}
+
+	return s
+}
@@ -0,0 +1,231 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Supported report formats.
+const (
+	FormatCSV     = "csv"
+	FormatJSON    = "json"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+	FormatXLSX    = "xlsx"
+)
+
+// Table is a report or summary as rows of formatted values.
+type Table struct {
+	Headers []string
+	Rows    [][]string
+}
+
+// Writer writes the report and summary to the path, returning the files it wrote.
+type Writer interface {
+	Write(path string, report, summary Table) (files []string, err error)
+}
+
+// NewWriter returns the writer for the format, or for the path's extension if it is empty.
+func NewWriter(format, path string) (Writer, error) {
+
+	if format == "" {
+		format = FormatFromPath(path)
+	}
+
+	switch format {
+	case FormatCSV:
+		return tableFilesWriter{writeTable: writeCSV}, nil
+	case FormatJSON:
+		return tableFilesWriter{writeTable: writeJSON}, nil
+	case FormatNDJSON:
+		return tableFilesWriter{writeTable: writeNDJSON}, nil
+	case FormatParquet:
+		return tableFilesWriter{writeTable: writeParquet}, nil
+	case FormatXLSX:
+		return xlsxWriter{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown report format %q", format)
+}
+
+// WriteTable writes a single table in one of the text formats, CSV when the format is empty.
+func WriteTable(w io.Writer, format string, t Table) error {
+	switch format {
+	case "", FormatCSV:
+		return writeCSV(w, t)
+	case FormatJSON:
+		return writeJSON(w, t)
+	case FormatNDJSON:
+		return writeNDJSON(w, t)
+	}
+	return fmt.Errorf("writing a single %s table is not supported", format)
+}
+
+// FormatFromPath returns the format of the path's extension, CSV when it has no known one.
+func FormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	case ".parquet":
+		return FormatParquet
+	case ".xlsx":
+		return FormatXLSX
+	}
+	return FormatCSV
+}
+
+// tableFilesWriter writes the report to the output path and the summary next to it, one table per file.
+type tableFilesWriter struct {
+	writeTable func(w io.Writer, t Table) error
+}
+
+func (tw tableFilesWriter) Write(path string, report, summary Table) ([]string, error) {
+
+	if err := writeTableFile(path, report, tw.writeTable); err != nil {
+		return nil, err
+	}
+
+	if err := writeTableFile(summaryPath(path), summary, tw.writeTable); err != nil {
+		return nil, err
+	}
+
+	return []string{path, summaryPath(path)}, nil
+}
+
+func writeTableFile(path string, t Table, writeTable func(w io.Writer, t Table) error) error {
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bw := bufio.NewWriter(f)
+	if err := writeTable(bw, t); err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// summaryPath returns the path of the summary written next to the report.
+func summaryPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-summary" + ext
+}
+
+func writeCSV(w io.Writer, t Table) error {
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Headers); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(t.Rows); err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
+
+// writeJSON writes the table as an array of objects keyed by header.
+func writeJSON(w io.Writer, t Table) error {
+
+	objects := make([]json.RawMessage, 0, len(t.Rows))
+	for _, row := range t.Rows {
+		obj, err := rowObject(t.Headers, row)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, obj)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(objects)
+}
+
+// writeNDJSON writes the table as one object keyed by header per line.
+func writeNDJSON(w io.Writer, t Table) error {
+
+	for _, row := range t.Rows {
+		obj, err := rowObject(t.Headers, row)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(obj, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rowObject encodes the row as a JSON object with its fields in header order.
+func rowObject(headers, row []string) (json.RawMessage, error) {
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+
+	b.WriteByte('{')
+	for i, h := range headers {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if err := enc.Encode(h); err != nil {
+			return nil, err
+		}
+		b.Truncate(b.Len() - 1)
+		b.WriteByte(':')
+		if err := enc.Encode(row[i]); err != nil {
+			return nil, err
+		}
+		b.Truncate(b.Len() - 1)
+	}
+	b.WriteByte('}')
+
+	return b.Bytes(), nil
+}
+
+// checksumPath returns the path of the checksums written next to the report.
+func checksumPath(path string) string {
+	return path + ".sha256"
+}
+
+// writeChecksums writes the SHA-256 of every file in the format of sha256sum.
+func writeChecksums(path string, files []string) error {
+
+	var b strings.Builder
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("could not hash %s: %w", file, err)
+		}
+
+		fmt.Fprintf(&b, "%s  %s\n", hex.EncodeToString(h.Sum(nil)), filepath.Base(file))
+	}
+
+	return ioutil.WriteFile(path, []byte(b.String()), 0644)
+}
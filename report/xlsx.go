@@ -0,0 +1,204 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// maxSheetName is the longest sheet name Excel accepts, in UTF-16 code units.
+const maxSheetName = 31
+
+// xlsxWriter writes a workbook with a sheet per account and a summary sheet.
+type xlsxWriter struct{}
+
+type xlsxSheet struct {
+	name  string
+	table Table
+}
+
+func (xlsxWriter) Write(path string, report, summary Table) ([]string, error) {
+
+	sheets := splitByAccount(report)
+	sheets = append(sheets, xlsxSheet{name: "summary", table: summary})
+	uniqueSheetNames(sheets)
+
+	f := excelize.NewFile()
+	if err := writeWorkbook(f, sheets); err != nil {
+		return nil, fmt.Errorf("could not write %s: %w", path, err)
+	}
+	if err := f.SaveAs(path); err != nil {
+		return nil, err
+	}
+
+	return []string{path}, nil
+}
+
+// splitByAccount returns a sheet per account, in the order the accounts appear.
+func splitByAccount(t Table) []xlsxSheet {
+
+	var sheets []xlsxSheet
+	index := map[string]int{}
+	for _, row := range t.Rows {
+		acc := row[0]
+		i, ok := index[acc]
+		if !ok {
+			i = len(sheets)
+			index[acc] = i
+			sheets = append(sheets, xlsxSheet{name: acc, table: Table{Headers: t.Headers}})
+		}
+		sheets[i].table.Rows = append(sheets[i].table.Rows, row)
+	}
+
+	return sheets
+}
+
+// uniqueSheetNames shortens names to what Excel accepts and numbers duplicates.
+func uniqueSheetNames(sheets []xlsxSheet) {
+
+	seen := map[string]bool{}
+	for i := range sheets {
+		base := sheetName(sheets[i].name)
+		name := base
+		for n := 2; seen[strings.ToLower(name)]; n++ {
+			suffix := "~" + strconv.Itoa(n)
+			name = truncateUTF16(base, maxSheetName-len(suffix)) + suffix
+		}
+		seen[strings.ToLower(name)] = true
+		sheets[i].name = name
+	}
+}
+
+// sheetName replaces the characters Excel doesn't accept in sheet names.
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = truncateUTF16(name, maxSheetName)
+
+	if strings.HasPrefix(name, "'") {
+		name = "_" + name[1:]
+	}
+	if strings.HasSuffix(name, "'") {
+		name = name[:len(name)-1] + "_"
+	}
+	if name == "" {
+		name = "_"
+	}
+
+	return name
+}
+
+// truncateUTF16 shortens s to at most n UTF-16 code units without splitting a character.
+func truncateUTF16(s string, n int) string {
+	units := 0
+	for i, r := range s {
+		size := len(utf16.Encode([]rune{r}))
+		if units+size > n {
+			return s[:i]
+		}
+		units += size
+	}
+	return s
+}
+
+// writeWorkbook writes every sheet with its headers as the first row.
+func writeWorkbook(f *excelize.File, sheets []xlsxSheet) error {
+
+	for i, sheet := range sheets {
+		if i == 0 {
+			f.SetSheetName(f.GetSheetName(0), sheet.name)
+		} else {
+			f.NewSheet(sheet.name)
+		}
+
+		sw, err := f.NewStreamWriter(sheet.name)
+		if err != nil {
+			return err
+		}
+
+		rows := append([][]string{sheet.table.Headers}, sheet.table.Rows...)
+		for r, row := range rows {
+			cell, err := excelize.CoordinatesToCellName(1, r+1)
+			if err != nil {
+				return err
+			}
+			values := make([]interface{}, len(row))
+			for c, value := range row {
+				values[c] = value
+			}
+			if err := sw.SetRow(cell, values); err != nil {
+				return err
+			}
+		}
+
+		if err := sw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readXLSX reads the account sheets of a workbook written by xlsxWriter back into one table.
+func readXLSX(path string) (Table, error) {
+
+	sheets, err := readWorkbook(path)
+	if err != nil {
+		return Table{}, err
+	}
+	if len(sheets) < 2 {
+		return Table{}, errors.New("no account sheets, the report has no rows")
+	}
+
+	t := Table{Headers: sheets[0].table.Headers}
+	for _, sheet := range sheets[:len(sheets)-1] {
+		if strings.Join(sheet.table.Headers, "\x00") != strings.Join(t.Headers, "\x00") {
+			return Table{}, fmt.Errorf("sheet %s has other columns than sheet %s", sheet.name, sheets[0].name)
+		}
+		t.Rows = append(t.Rows, sheet.table.Rows...)
+	}
+
+	return t, nil
+}
+
+// readWorkbook reads the sheets of a workbook in order, headers first.
+func readWorkbook(path string) ([]xlsxSheet, error) {
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var sheets []xlsxSheet
+	for _, name := range f.GetSheetList() {
+		rows, err := f.GetRows(name)
+		if err != nil {
+			return nil, fmt.Errorf("sheet %s: %w", name, err)
+		}
+		if len(rows) == 0 {
+			sheets = append(sheets, xlsxSheet{name: name})
+			continue
+		}
+
+		// Rows end at their last non-empty cell.
+		t := Table{Headers: rows[0]}
+		for _, row := range rows[1:] {
+			if len(row) > len(t.Headers) {
+				return nil, fmt.Errorf("sheet %s has values outside its columns", name)
+			}
+			t.Rows = append(t.Rows, append(row, make([]string, len(t.Headers)-len(row))...))
+		}
+
+		sheets = append(sheets, xlsxSheet{name: name, table: t})
+	}
+
+	return sheets, nil
+}
@@ -0,0 +1,125 @@
+package report
+
+import (
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestXLSXWriterRoundTrip(t *testing.T) {
+
+	headers := []string{"account", "period", "amount"}
+	summary := Table{Headers: []string{"account", "total"}, Rows: [][]string{{"cosmos1abc", "3"}}}
+
+	var wideHeaders []string
+	for i := 0; i < 30; i++ {
+		wideHeaders = append(wideHeaders, "column_"+strconv.Itoa(i))
+	}
+	wideRow := make([]string, len(wideHeaders))
+	for i := range wideRow {
+		wideRow[i] = "v" + strconv.Itoa(i)
+	}
+
+	longName := strings.Repeat("é", 40)
+	astral := strings.Repeat("😀", 20)
+
+	tests := []struct {
+		name       string
+		report     Table
+		wantSheets []string
+		wantTables []Table
+	}{
+		{
+			name:       "no rows",
+			report:     Table{Headers: headers},
+			wantSheets: []string{"summary"},
+			wantTables: []Table{summary},
+		},
+		{
+			name: "rows split by account",
+			report: Table{Headers: headers, Rows: [][]string{
+				{"cosmos1abc", "2021-01", "1"},
+				{"cosmos1xyz", "2021-01", ""},
+				{"cosmos1abc", "2021-02", "2 <&>"},
+			}},
+			wantSheets: []string{"cosmos1abc", "cosmos1xyz", "summary"},
+			wantTables: []Table{
+				{Headers: headers, Rows: [][]string{{"cosmos1abc", "2021-01", "1"}, {"cosmos1abc", "2021-02", "2 <&>"}}},
+				{Headers: headers, Rows: [][]string{{"cosmos1xyz", "2021-01", ""}}},
+				summary,
+			},
+		},
+		{
+			name:       "wide",
+			report:     Table{Headers: wideHeaders, Rows: [][]string{wideRow}},
+			wantSheets: []string{"v0", "summary"},
+			wantTables: []Table{{Headers: wideHeaders, Rows: [][]string{wideRow}}, summary},
+		},
+		{
+			// Excel limits names to 31 UTF-16 code units, compares them case insensitively and rejects
+			// some characters and leading or trailing apostrophes.
+			name: "non-ASCII and invalid sheet names",
+			report: Table{Headers: headers, Rows: [][]string{
+				{longName, "2021-01", "1"},
+				{longName + "x", "2021-01", "2"},
+				{astral, "2021-01", "3"},
+				{"Summary", "2021-01", "4"},
+				{"'a/b'", "2021-01", "5"},
+			}},
+			wantSheets: []string{
+				strings.Repeat("é", 31),
+				strings.Repeat("é", 29) + "~2",
+				strings.Repeat("😀", 15),
+				"Summary",
+				"_a_b_",
+				"summary~2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "report.xlsx")
+			if _, err := (xlsxWriter{}).Write(path, tt.report, summary); err != nil {
+				t.Fatal(err)
+			}
+
+			names, tables, err := readWorkbookFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(names, tt.wantSheets) {
+				t.Errorf("sheets = %q, want %q", names, tt.wantSheets)
+			}
+			for _, name := range names {
+				if n := len(utf16.Encode([]rune(name))); n > maxSheetName {
+					t.Errorf("sheet %q is %d UTF-16 units long", name, n)
+				}
+			}
+			if tt.wantTables != nil && !reflect.DeepEqual(tables, tt.wantTables) {
+				t.Errorf("tables = %q, want %q", tables, tt.wantTables)
+			}
+		})
+	}
+}
+
+// readWorkbookFile returns the sheet names and tables of the workbook at the path.
+func readWorkbookFile(file string) ([]string, []Table, error) {
+
+	sheets, err := readWorkbook(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var names []string
+	var tables []Table
+	for _, sheet := range sheets {
+		names = append(names, sheet.name)
+		tables = append(tables, sheet.table)
+	}
+
+	return names, tables, nil
+}